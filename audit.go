@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// runAudit compares the filings EDGAR lists for the tracked companies with
+// the filings stored and prints the coverage of every company followed by
+// the missing and disappeared filings. It fails when companies could not be
+// audited.
+func runAudit(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: extractor audit")
+	}
+	extractor, err := newExtractor()
+	if err != nil {
+		return err
+	}
+	report, err := extractor.Audit(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cov := range report.Companies {
+		fmt.Printf("coverage\t%s\t%.2f%%\t%d/%d\n", cov.CIK, cov.Coverage, cov.Stored, cov.Expected)
+	}
+	for _, cov := range report.Companies {
+		for _, id := range cov.Missing {
+			fmt.Printf("missing\t%s\t%s\n", cov.CIK, id)
+		}
+		for _, id := range cov.Disappeared {
+			fmt.Printf("disappeared\t%s\t%s\n", cov.CIK, id)
+		}
+	}
+	if len(report.Failed) > 0 {
+		return errors.New(fmt.Sprintf("Could not audit %d companies: %s", len(report.Failed), strings.Join(report.Failed, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sec-data-pipeline/extractor/external"
+)
+
+const companiesUsage = "usage: extractor companies add|remove <ticker or CIK>... | list"
+
+// runCompanies manages the tracked companies, e.g.
+// "extractor companies add AAPL 0000789019" or "extractor companies list".
+func runCompanies(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New(companiesUsage)
+	}
+	switch args[0] {
+	case "list":
+	case "add", "remove":
+		if len(args) < 2 {
+			return errors.New(companiesUsage)
+		}
+	default:
+		return errors.New(companiesUsage)
+	}
+	awsSession, err := newSession()
+	if err != nil {
+		return err
+	}
+	db, err := newDatabase(awsSession)
+	if err != nil {
+		return err
+	}
+	if args[0] == "list" {
+		companies, err := db.GetCompanies(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cmp := range companies {
+			fmt.Printf("%s\t%s\n", cmp.CIK, cmp.Name)
+		}
+		return nil
+	}
+	resolver := &tickerResolver{}
+	for _, query := range args[1:] {
+		cik, name, err := resolver.resolve(ctx, query)
+		if err != nil {
+			return err
+		}
+		var changed bool
+		if args[0] == "add" {
+			changed, err = db.AddCompany(ctx, cik, name)
+		} else {
+			changed, err = db.RemoveCompany(ctx, cik)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case !changed && args[0] == "add":
+			fmt.Printf("Already tracking %s (%s)\n", cik, query)
+		case !changed:
+			fmt.Printf("Not tracking %s (%s)\n", cik, query)
+		case args[0] == "add":
+			fmt.Printf("Added %s %s\n", cik, name)
+		default:
+			fmt.Printf("Removed %s (%s)\n", cik, query)
+		}
+	}
+	return nil
+}
+
+// tickerResolver loads the ticker file only once the first ticker has to be
+// resolved, so managing companies by CIK works offline and without the
+// EDGAR configuration.
+type tickerResolver struct {
+	tickers []*external.Ticker
+}
+
+func (r *tickerResolver) resolve(ctx context.Context, query string) (string, string, error) {
+	if cik, err := external.NormalizeCIK(query); err == nil {
+		return cik, "", nil
+	}
+	if r.tickers == nil {
+		var err error
+		if path := os.Getenv("TICKERS_FILE"); len(path) > 0 {
+			r.tickers, err = external.LoadTickers(path)
+		} else {
+			var api *external.API
+			api, err = newAPI()
+			if err == nil {
+				r.tickers, err = api.GetTickers(ctx)
+			}
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	t := external.FindTicker(r.tickers, query)
+	if t == nil {
+		return "", "", errors.New(fmt.Sprintf("Unknown ticker '%s'", query))
+	}
+	return t.CIK, t.Name, nil
+}
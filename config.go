@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/external"
+)
+
+func envOrPanic(key string) string {
+	value := os.Getenv(key)
+	if len(value) < 1 {
+		panic(errors.New(fmt.Sprintf("Environment variable '%s' must be specified", key)))
+	}
+	return value
+}
+
+func envIntOrDefault(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if len(value) < 1 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Environment variable '%s' must be an integer", key))
+	}
+	return n, nil
+}
+
+func envFloatOrDefault(key string, def float64) (float64, error) {
+	value := os.Getenv(key)
+	if len(value) < 1 {
+		return def, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Environment variable '%s' must be a number", key))
+	}
+	return n, nil
+}
+
+func envDurationOrDefault(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if len(value) < 1 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Environment variable '%s' must be a duration like '30s'", key))
+	}
+	return d, nil
+}
+
+func envDateOrDefault(key string, def time.Time) (time.Time, error) {
+	value := os.Getenv(key)
+	if len(value) < 1 {
+		return def, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Environment variable '%s' must be a date like '2023-12-31'", key))
+	}
+	return t, nil
+}
+
+// newIndexRange reads the days of the EDGAR index to crawl, by default the
+// last INDEX_DAYS days including today.
+func newIndexRange() (time.Time, time.Time, error) {
+	to, err := envDateOrDefault("INDEX_TO", time.Now().UTC())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	days, err := envIntOrDefault("INDEX_DAYS", 7)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := envDateOrDefault("INDEX_FROM", to.AddDate(0, 0, 1-days))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("Environment variable 'INDEX_FROM' must not be after 'INDEX_TO'")
+	}
+	return from, to, nil
+}
+
+func newClientConfig() (*external.Config, error) {
+	timeout, err := envDurationOrDefault("HTTP_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	totalTimeout, err := envDurationOrDefault("HTTP_TOTAL_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	maxIdle, err := envIntOrDefault("HTTP_MAX_IDLE_CONNS", 10)
+	if err != nil {
+		return nil, err
+	}
+	cacheMaxSize, err := envIntOrDefault("HTTP_CACHE_MAX_SIZE", 1<<30)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTL, err := envDurationOrDefault("HTTP_CACHE_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &external.Config{
+		Name:         envOrPanic("SEC_USER_AGENT_NAME"),
+		Email:        envOrPanic("SEC_USER_AGENT_EMAIL"),
+		Timeout:      timeout,
+		TotalTimeout: totalTimeout,
+		Proxy:        os.Getenv("HTTP_PROXY_URL"),
+		MaxIdleConns: maxIdle,
+		CacheDir:     os.Getenv("HTTP_CACHE_DIR"),
+		CacheMaxSize: int64(cacheMaxSize),
+		CacheTTL:     cacheTTL,
+		CassetteDir:  os.Getenv("HTTP_CASSETTE_DIR"),
+		CassetteMode: os.Getenv("HTTP_CASSETTE_MODE"),
+
+		ArchivesURL:    os.Getenv("EDGAR_ARCHIVES_URL"),
+		SubmissionsURL: os.Getenv("EDGAR_SUBMISSIONS_URL"),
+		TickersURL:     os.Getenv("EDGAR_TICKERS_URL"),
+		CurrentURL:     os.Getenv("EDGAR_CURRENT_URL"),
+	}, nil
+}
+
+// newRateLimiter reads HOST_RATE_LIMITS as a comma separated list of
+// host=rate:burst entries, e.g. "data.sec.gov=5:2,www.sec.gov=5:1".
+func newRateLimiter() (*external.RateLimiter, error) {
+	rate, err := envFloatOrDefault("RATE_LIMIT", 5)
+	if err != nil {
+		return nil, err
+	}
+	burst, err := envIntOrDefault("RATE_BURST", 1)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := external.NewRateLimiter(rate, burst)
+	if err != nil {
+		return nil, errors.New("Invalid 'RATE_LIMIT' or 'RATE_BURST', " + err.Error())
+	}
+	hosts := os.Getenv("HOST_RATE_LIMITS")
+	if len(hosts) < 1 {
+		return limiter, nil
+	}
+	for _, entry := range strings.Split(hosts, ",") {
+		host, limit, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Invalid host rate limit '%s'", entry))
+		}
+		rateStr, burstStr, _ := strings.Cut(limit, ":")
+		hostRate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid rate in host rate limit '%s'", entry))
+		}
+		hostBurst := 1
+		if len(burstStr) > 0 {
+			hostBurst, err = strconv.Atoi(burstStr)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid burst in host rate limit '%s'", entry))
+			}
+		}
+		if err := limiter.SetHostLimit(host, hostRate, hostBurst); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid host rate limit '%s', %s", entry, err.Error()))
+		}
+	}
+	return limiter, nil
+}
@@ -1,89 +1,267 @@
 package external
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 type API struct {
-	client    client
-	fileURL   string
-	filingURL string
+	client         client
+	limiter        *RateLimiter
+	retry          retryPolicy
+	totalTimeout   time.Duration
+	sleep          func(context.Context, time.Duration) error
+	fileURL        string
+	submissionsURL string
+	tickersURL     string
+	indexURL       string
+	currentURL     string
 }
 
-func NewAPI() *API {
-	return &API{
-		client:    newWebClient(),
-		fileURL:   "https://www.sec.gov/Archives/edgar/data/",
-		filingURL: "https://data.sec.gov/submissions/CIK",
+func NewAPI(cfg *Config, limiter *RateLimiter) (*API, error) {
+	webClient, err := newWebClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var c client = &mirrorClient{next: webClient}
+	if len(cfg.CassetteDir) > 0 {
+		c, err = newCassetteClient(c, cfg.CassetteDir, cfg.CassetteMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.CacheDir) > 0 {
+		c, err = newCacheClient(c, cfg.CacheDir, cfg.CacheMaxSize, cfg.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	retry := defaultRetryPolicy()
+	if cfg.RetryAttempts > 0 {
+		retry.attempts = cfg.RetryAttempts
+	}
+	if cfg.RetryBaseDelay > 0 {
+		retry.base = cfg.RetryBaseDelay
 	}
+	archivesURL := withDefault(cfg.ArchivesURL, "https://www.sec.gov/Archives/edgar/")
+	return &API{
+		client:         c,
+		limiter:        limiter,
+		retry:          retry,
+		totalTimeout:   cfg.TotalTimeout,
+		sleep:          sleepContext,
+		fileURL:        archivesURL + "data/",
+		submissionsURL: withDefault(cfg.SubmissionsURL, "https://data.sec.gov/submissions/"),
+		tickersURL:     withDefault(cfg.TickersURL, "https://www.sec.gov/files/company_tickers_exchange.json"),
+		indexURL:       archivesURL,
+		currentURL: withDefault(
+			cfg.CurrentURL,
+			"https://www.sec.gov/cgi-bin/browse-edgar?action=getcurrent&owner=include&output=atom",
+		),
+	}, nil
 }
 
-func (api *API) GetFilings(cik string) ([]*Filing, error) {
-	req, err := api.client.buildRequest(api.filingURL + cik + ".json")
-	if err != nil {
-		return nil, err
+// withDefault returns value, or def if it is empty, making sure directory
+// URLs end in a slash.
+func withDefault(value string, def string) string {
+	if len(value) < 1 {
+		return def
 	}
-	res, err := api.client.sendRequest(req)
-	if err != nil {
-		return nil, err
+	if strings.HasSuffix(def, "/") && !strings.HasSuffix(value, "/") {
+		return value + "/"
 	}
-	data, err := api.client.getData(res)
+	return value
+}
+
+// GetSubmissions reads the profile and recent filings of a company and, if
+// history is set, every older submissions page EDGAR links from the recent
+// filings.
+func (api *API) GetSubmissions(ctx context.Context, cik string, policy *FormPolicy, history bool) (*Submissions, error) {
+	data, err := api.fetch(ctx, api.submissionsURL+"CIK"+cik+".json")
 	if err != nil {
 		return nil, err
 	}
 	filRes := &filingsResponse{}
 	if err := json.Unmarshal(data, filRes); err != nil {
-		return nil, errors.New("Could not process JSON into struct filingsResponse, " + err.Error())
+		return nil, malformed("filingsResponse", err)
 	}
-	return transformFilings(filRes), nil
-}
-
-func (api *API) GetMainFile(cik string, fil *Filing) (*file, error) {
-	req, err := api.client.buildRequest(api.fileURL + cik + "/" + fil.GetID() + "/index.json")
-	if err != nil {
+	if err := checkCIK(cik, filRes.CIK); err != nil {
 		return nil, err
 	}
-	res, err := api.client.sendRequest(req)
-	if err != nil {
+	sub := &Submissions{Company: transformCompany(filRes)}
+	if err := sub.transform(&filRes.Filings.Recent, policy); err != nil {
 		return nil, err
 	}
-	data, err := api.client.getData(res)
+	if history {
+		for _, page := range filRes.Filings.Files {
+			data, err := api.fetch(ctx, api.submissionsURL+page.Name)
+			if err != nil {
+				return nil, fmt.Errorf("Could not get submissions page %s, %w", page.Name, err)
+			}
+			pageRes := &recent{}
+			if err := json.Unmarshal(data, pageRes); err != nil {
+				return nil, malformed("recent", err)
+			}
+			if err := sub.transform(pageRes, policy); err != nil {
+				return nil, fmt.Errorf("Could not get submissions page %s, %w", page.Name, err)
+			}
+		}
+	}
+	return sub, nil
+}
+
+func (api *API) GetFiles(ctx context.Context, cik string, fil *Filing) ([]*file, error) {
+	data, err := api.fetch(ctx, api.fileURL+cik+"/"+fil.GetID()+"/index.json")
 	if err != nil {
 		return nil, err
 	}
 	filRes := &filesResponse{}
 	if err := json.Unmarshal(data, filRes); err != nil {
-		return nil, errors.New("Could not process JSON into struct filesResponse, " + err.Error())
+		return nil, malformed("filesResponse", err)
 	}
-	files := transformFiles(filRes)
-	mainFile, err := getFile(files, fil.mainFile)
+	return transformFiles(filRes), nil
+}
+
+func (api *API) GetMainFile(ctx context.Context, cik string, fil *Filing) (*file, error) {
+	files, err := api.GetFiles(ctx, cik, fil)
 	if err != nil {
 		return nil, err
 	}
-	mainFile.Content, err = api.getFileContent(cik, fil.GetID(), fil.mainFile)
+	mainFile, err := getFile(files, fil.mainFile)
 	if err != nil {
-		return nil, errors.New("Could not get content of main file, " + err.Error())
+		return nil, err
 	}
 	return mainFile, nil
 }
 
-func (api *API) getFileContent(cik string, secID string, name string) ([]byte, error) {
-	req, err := api.client.buildRequest(api.fileURL + cik + "/" + secID + "/" + name)
+// OpenFile starts the download of a file of a filing. The beginning of the
+// content is validated before the reader is returned, the caller has to
+// close it.
+func (api *API) OpenFile(ctx context.Context, cik string, fil *Filing, name string) (io.ReadCloser, error) {
+	urlStr := api.fileURL + cik + "/" + fil.GetID() + "/" + name
+	var body io.ReadCloser
+	err := api.retrying(ctx, func() error {
+		res, err := api.send(ctx, urlStr)
+		if err != nil {
+			return err
+		}
+		stream, err := api.client.getStream(res)
+		if err != nil {
+			return err
+		}
+		contentType := ""
+		if res != nil {
+			contentType = res.Header.Get("Content-Type")
+		}
+		body, err = validateStream(name, name == fil.GetMainFileName(), contentType, stream)
+		if err != nil {
+			api.evict(urlStr)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Could not open file %s, %w", name, err)
+	}
+	return body, nil
+}
+
+func (api *API) LimiterStats() LimiterStats {
+	if api.limiter == nil {
+		return LimiterStats{}
 	}
-	res, err := api.client.sendRequest(req)
+	return api.limiter.Stats()
+}
+
+func (api *API) fetch(ctx context.Context, urlStr string) ([]byte, error) {
+	var data []byte
+	err := api.retrying(ctx, func() error {
+		res, err := api.send(ctx, urlStr)
+		if err != nil {
+			return err
+		}
+		data, err = api.client.getData(res)
+		if err != nil {
+			return err
+		}
+		if err := checkBlockPage(urlStr, data); err != nil {
+			api.evict(urlStr)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	data, err := api.client.getData(res)
+	return data, nil
+}
+
+// retrying retries transient failures of do with exponential backoff and
+// returns the last error once the attempts of the retry policy or the total
+// timeout are used up, when EDGAR asks to wait longer than the longest delay
+// of the policy, or as soon as ctx is done.
+func (api *API) retrying(ctx context.Context, do func() error) error {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := do()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !isTransient(err) || attempt+1 >= api.retry.attempts {
+			return err
+		}
+		if wait := retryAfter(err); wait > api.retry.max {
+			return fmt.Errorf("Giving up, asked to retry after %s, %w", wait, err)
+		}
+		wait := api.retry.backoff(attempt, err)
+		if api.totalTimeout > 0 && time.Since(start)+wait > api.totalTimeout {
+			return fmt.Errorf("Giving up after %s, %w", time.Since(start).Round(time.Millisecond), err)
+		}
+		if err := api.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (api *API) send(ctx context.Context, urlStr string) (*http.Response, error) {
+	req, err := api.client.buildRequest(ctx, urlStr)
 	if err != nil {
 		return nil, err
 	}
-	return data, nil
+	if api.limiter != nil && !api.skipsLimiter(urlStr) {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.limiter.Wait(ctx, u.Host); err != nil {
+			return nil, err
+		}
+	}
+	return api.client.sendRequest(req)
+}
+
+func (api *API) skipsLimiter(urlStr string) bool {
+	if strings.HasPrefix(urlStr, "file://") {
+		return true
+	}
+	if c, ok := api.client.(cache); ok && c.fresh(urlStr) {
+		return true
+	}
+	c, ok := api.client.(offline)
+	return ok && c.offline()
+}
+
+func (api *API) evict(urlStr string) {
+	if c, ok := api.client.(cache); ok {
+		c.evict(urlStr)
+	}
 }
 
 type Filing struct {
@@ -93,15 +271,37 @@ type Filing struct {
 	FilingDate sql.NullTime
 	ReportDate sql.NullTime
 	AcceptDate sql.NullTime
+
+	PrimaryDocDescription string
+	Act                   string
+	FileNumber            string
+	FilmNumber            string
+	Items                 string
+	Size                  int64
+	IsXBRL                bool
+	IsInlineXBRL          bool
+}
+
+// NewFiling returns a filing known only by its accession number and primary
+// document, e.g. one read back from the database, to download its files again.
+func NewFiling(secID string, mainFile string) *Filing {
+	if len(secID) == 18 && !strings.Contains(secID, "-") {
+		secID = secID[:10] + "-" + secID[10:12] + "-" + secID[12:]
+	}
+	return &Filing{secID: secID, mainFile: mainFile}
 }
 
 func (f *Filing) GetID() string {
 	return strings.Replace(f.secID, "-", "", -1)
 }
 
+func (f *Filing) GetMainFileName() string {
+	return f.mainFile
+}
+
 type file struct {
 	Name         string
-	Content      []byte
+	Size         int64
 	LastModified sql.NullTime
 }
 
@@ -125,5 +325,5 @@ func getFile(files []*file, name string) (*file, error) {
 			return file, nil
 		}
 	}
-	return nil, errors.New("File not in provided list")
+	return nil, fmt.Errorf("%w, file '%s' not in provided list", ErrNotFound, name)
 }
@@ -1,8 +1,11 @@
 package external
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"errors"
+	"io"
 	"net/http"
 	"testing"
 	"time"
@@ -74,28 +77,128 @@ func TestGetExtension(t *testing.T) {
 	}
 }
 
-func TestGetFilings(t *testing.T) {
+func TestGetSubmissions(t *testing.T) {
+	recentRes := []byte(`
+		{
+			"cik":"320193",
+			"name":"Apple Inc.",
+			"tickers":["AAPL"],
+			"filings":{
+				"recent":{
+					"accessionNumber":["0000320193-23-000106","0000320193-23-000105"],
+					"filingDate":["2023-11-03","2023-11-02"],
+					"reportDate":["2023-09-30",""],
+					"acceptanceDateTime":["2023-11-02T18:08:27.000Z","2023-11-02T18:04:07.000Z"],
+					"form":["10-K","8-K"],
+					"primaryDocument":["aapl-20230930.htm","aapl-20231102.htm"]
+				},
+				"files":[{"name":"CIK0000320193-submissions-001.json","filingCount":2}]
+			}
+		}
+	`)
+	pageRes := []byte(`
+		{
+			"accessionNumber":["0000320193-94-000016","0000320193-94-000015"],
+			"filingDate":["1994-12-13","1994-08-10"],
+			"reportDate":["1994-09-30","1994-06-24"],
+			"acceptanceDateTime":["1994-12-13T00:00:00.000Z","1994-08-10T00:00:00.000Z"],
+			"form":["10-K","10-Q"],
+			"primaryDocument":["main.htm","main.htm"]
+		}
+	`)
+	recentWant := &Filing{
+		secID: "0000320193-23-000106",
+		Form:  "10-K",
+		FilingDate: sql.NullTime{
+			Time:  time.Date(2023, time.November, 3, 0, 0, 0, 0, time.UTC),
+			Valid: true,
+		},
+		ReportDate: sql.NullTime{
+			Time:  time.Date(2023, time.September, 30, 0, 0, 0, 0, time.UTC),
+			Valid: true,
+		},
+		AcceptDate: sql.NullTime{
+			Time:  time.Date(2023, time.November, 2, 18, 8, 27, 0, time.UTC),
+			Valid: true,
+		},
+	}
 	var tests = []struct {
 		name    string
-		mockRes []byte
+		mockRes [][]byte
+		history bool
 		err     error
 		want    []*Filing
-	}{}
+	}{
+		{"Recent filings only", [][]byte{recentRes}, false, nil, []*Filing{recentWant}},
+		{
+			"Recent filings and history page",
+			[][]byte{recentRes, pageRes},
+			true,
+			nil,
+			[]*Filing{
+				recentWant,
+				{
+					secID: "0000320193-94-000016",
+					Form:  "10-K",
+					FilingDate: sql.NullTime{
+						Time:  time.Date(1994, time.December, 13, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+					ReportDate: sql.NullTime{
+						Time:  time.Date(1994, time.September, 30, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+					AcceptDate: sql.NullTime{
+						Time:  time.Date(1994, time.December, 13, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+				},
+				{
+					secID: "0000320193-94-000015",
+					Form:  "10-Q",
+					FilingDate: sql.NullTime{
+						Time:  time.Date(1994, time.August, 10, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+					ReportDate: sql.NullTime{
+						Time:  time.Date(1994, time.June, 24, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+					AcceptDate: sql.NullTime{
+						Time:  time.Date(1994, time.August, 10, 0, 0, 0, 0, time.UTC),
+						Valid: true,
+					},
+				},
+			},
+		},
+		{"Missing history page", [][]byte{recentRes}, true, errors.New(""), nil},
+		{"Malformed history page", [][]byte{recentRes, []byte(`{"form":`)}, true, errors.New(""), nil},
+		{"Malformed response", [][]byte{[]byte(`{"filings":`)}, false, errors.New(""), nil},
+		{"Submissions of another CIK", [][]byte{[]byte(`{"cik":"789019","filings":{}}`)}, false, ErrMalformed, nil},
+	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			api := newTestAPI([][]byte{test.mockRes})
-			got, err := api.GetFilings("")
+			api := newTestAPI(test.mockRes)
+			sub, err := api.GetSubmissions(context.Background(), "320193", DefaultFormPolicy(), test.history)
 			if err != nil && test.err == nil {
 				t.Errorf(err.Error())
 				return
 			}
 			if test.err != nil && err == nil {
-				t.Errorf("expected error to be trown, but got list of length: %d", len(got))
+				t.Errorf("expected error to be trown, but got list of length: %d", len(sub.Filings))
 				return
 			}
 			if test.err != nil && err != nil {
 				return
 			}
+			if sub.Company.Name != "Apple Inc." || sub.Company.CIK != "320193" {
+				t.Errorf("got company %s (%s), want Apple Inc. (320193)", sub.Company.Name, sub.Company.CIK)
+			}
+			got := sub.Filings
+			if len(got) != len(test.want) {
+				t.Errorf("got %d filings, want %d", len(got), len(test.want))
+				return
+			}
 			for i, v := range got {
 				if v.secID != test.want[i].secID {
 					t.Errorf("got %s, want %s", v.secID, test.want[i].secID)
@@ -120,14 +223,21 @@ func TestGetFilings(t *testing.T) {
 	}
 }
 
+var testDocument = []byte(`<html>
+<head><title>10-K</title></head>
+<body>
+<p>UNITED STATES SECURITIES AND EXCHANGE COMMISSION</p>
+<p>Washington, D.C. 20549</p>
+<p>FORM 10-K</p>
+<p>ANNUAL REPORT PURSUANT TO SECTION 13 OR 15(d) OF THE SECURITIES EXCHANGE ACT OF 1934</p>
+<p>For the fiscal year ended September 30, 2023</p>
+</body>
+</html>
+`)
+
 func TestGetMainFile(t *testing.T) {
-	type mock struct {
-		firstRes  []byte
-		secondRes []byte
-	}
-	var mocks []mock = []mock{
-		{
-			firstRes: []byte(`
+	var mocks [][]byte = [][]byte{
+		[]byte(`
 				{
 					"directory":{
 						"item":[
@@ -142,11 +252,8 @@ func TestGetMainFile(t *testing.T) {
 						]
 					}
 				}
-			`),
-			secondRes: []byte(`test`),
-		},
-		{
-			firstRes: []byte(`
+		`),
+		[]byte(`
 				{
 					"directory":{
 						"item":[
@@ -157,11 +264,8 @@ func TestGetMainFile(t *testing.T) {
 						]
 					}
 				}
-			`),
-			secondRes: []byte(`test`),
-		},
-		{
-			firstRes: []byte(`
+		`),
+		[]byte(`
 				{
 					"directory":{
 						"item":[
@@ -172,13 +276,11 @@ func TestGetMainFile(t *testing.T) {
 						]
 					}
 				}
-			`),
-			secondRes: []byte(`test`),
-		},
+		`),
 	}
 	var tests = []struct {
 		name    string
-		mockRes mock
+		mockRes []byte
 		err     error
 		want    *file
 	}{
@@ -192,7 +294,6 @@ func TestGetMainFile(t *testing.T) {
 					Time:  time.Date(2004, time.September, 10, 16, 47, 30, 0, time.UTC),
 					Valid: true,
 				},
-				Content: mocks[0].secondRes,
 			},
 		},
 		{"Main file not in file list", mocks[1], errors.New(""), &file{}},
@@ -203,17 +304,13 @@ func TestGetMainFile(t *testing.T) {
 			&file{Name: "main.htm", LastModified: sql.NullTime{
 				Time:  time.Date(2004, time.September, 10, 16, 47, 30, 0, time.UTC),
 				Valid: true,
-			},
-				Content: mocks[0].secondRes,
-			},
+			}},
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			api := newTestAPI(
-				[][]byte{test.mockRes.firstRes, test.mockRes.secondRes},
-			)
-			got, err := api.GetMainFile("", &Filing{mainFile: test.want.Name})
+			api := newTestAPI([][]byte{test.mockRes})
+			got, err := api.GetMainFile(context.Background(), "", &Filing{mainFile: test.want.Name})
 			if err != nil && test.err == nil {
 				t.Errorf(err.Error())
 				return
@@ -232,8 +329,97 @@ func TestGetMainFile(t *testing.T) {
 			if !ok {
 				t.Errorf(msg)
 			}
-			if string(got.Content) != string(test.want.Content) {
-				t.Errorf("got: %s, want: %s", string(got.Content), string(test.want.Content))
+		})
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fileName string
+		mockRes  []byte
+		err      error
+	}{
+		{"Valid document", "k2004.htm", testDocument, nil},
+		{"Document too small", "k2004.htm", []byte(`test`), ErrInvalidContent},
+		{"Small exhibit", "ex21.htm", []byte(`<html>test</html>`), nil},
+		{"Block page", "ex21.htm", []byte(`<html><title>Request Rate Threshold Exceeded</title></html>`), ErrBlockPage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := newTestAPI([][]byte{test.mockRes})
+			body, err := api.OpenFile(context.Background(), "", &Filing{mainFile: "k2004.htm"}, test.fileName)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("got error %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf(err.Error())
+				return
+			}
+			defer body.Close()
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Errorf(err.Error())
+				return
+			}
+			if string(got) != string(test.mockRes) {
+				t.Errorf("got: %s, want: %s", string(got), string(test.mockRes))
+			}
+		})
+	}
+}
+
+func TestFetchRetry(t *testing.T) {
+	rateLimited := &ResponseError{Status: 429, Err: ErrRateLimited, RetryAfter: 30 * time.Second}
+	var tests = []struct {
+		name   string
+		errs   []error
+		err    error
+		sleeps int
+	}{
+		{"Success without retry", []error{nil}, nil, 0},
+		{"Success after server error", []error{&ResponseError{Status: 503, Err: ErrServerError}, nil}, nil, 1},
+		{"Success after rate limit", []error{rateLimited, rateLimited, nil}, nil, 2},
+		{"Not found is not retried", []error{&ResponseError{Status: 404, Err: ErrNotFound}}, ErrNotFound, 0},
+		{"Attempts exhausted", []error{rateLimited, rateLimited, rateLimited}, ErrRateLimited, 2},
+		{
+			"Retry-After beyond max delay",
+			[]error{&ResponseError{Status: 429, Err: ErrRateLimited, RetryAfter: 2 * time.Hour}, nil},
+			ErrRateLimited,
+			0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := make([][]byte, len(test.errs))
+			for i := range data {
+				data[i] = []byte(`test`)
+			}
+			api := newTestAPI(data)
+			api.client.(*testClient).errs = test.errs
+			api.retry = retryPolicy{attempts: 3, base: time.Second, max: time.Minute}
+			var sleeps []time.Duration
+			api.sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+			_, err := api.fetch(context.Background(), "")
+			if test.err == nil && err != nil {
+				t.Errorf(err.Error())
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+			if len(sleeps) != test.sleeps {
+				t.Errorf("got %d retries, want %d", len(sleeps), test.sleeps)
+			}
+			for _, d := range sleeps {
+				if test.errs[0] == rateLimited && d < rateLimited.RetryAfter {
+					t.Errorf("retried after %s, before Retry-After of %s", d, rateLimited.RetryAfter)
+				}
 			}
 		})
 	}
@@ -241,10 +427,11 @@ func TestGetMainFile(t *testing.T) {
 
 type testClient struct {
 	data  [][]byte
+	errs  []error
 	index int
 }
 
-func (c *testClient) buildRequest(urlStr string) (*http.Request, error) {
+func (c *testClient) buildRequest(ctx context.Context, urlStr string) (*http.Request, error) {
 	return nil, nil
 }
 
@@ -258,9 +445,20 @@ func (c *testClient) getData(res *http.Response) ([]byte, error) {
 	}
 	data := c.data[c.index]
 	c.index++
+	if c.index <= len(c.errs) && c.errs[c.index-1] != nil {
+		return nil, c.errs[c.index-1]
+	}
 	return data, nil
 }
 
+func (c *testClient) getStream(res *http.Response) (io.ReadCloser, error) {
+	data, err := c.getData(res)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
 func newTestAPI(data [][]byte) *API {
 	return &API{client: &testClient{data: data, index: 0}}
 }
@@ -0,0 +1,335 @@
+package external
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache is implemented by clients which keep responses. Fresh responses are
+// served without a request, so the API does not wait on the rate limiter for
+// them, and content found invalid after the fact is evicted.
+type cache interface {
+	fresh(urlStr string) bool
+	evict(urlStr string)
+}
+
+type cacheMeta struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Stored time.Time   `json:"stored"`
+	Size   int64       `json:"size"`
+}
+
+// cacheClient keeps successful responses read with getData, i.e. JSON and
+// indexes, on disk with their ETag and Last-Modified headers. Documents
+// streamed with getStream are not kept. Responses younger than ttl are served right away,
+// older ones are revalidated with a conditional request and served from disk
+// on 304 Not Modified. The least recently used responses are removed once
+// the cache grows beyond maxSize.
+type cacheClient struct {
+	next    client
+	dir     string
+	maxSize int64
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu   sync.Mutex
+	size int64
+	// size is only known after the first scan of dir
+	scanned bool
+}
+
+func newCacheClient(next client, dir string, maxSize int64, ttl time.Duration) (*cacheClient, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New("Could not create HTTP cache directory, " + err.Error())
+	}
+	// responses of an earlier run which were never read completely
+	if leftovers, err := filepath.Glob(filepath.Join(dir, "tmp-*")); err == nil {
+		for _, name := range leftovers {
+			os.Remove(name)
+		}
+	}
+	return &cacheClient{next: next, dir: dir, maxSize: maxSize, ttl: ttl, now: time.Now}, nil
+}
+
+func (c *cacheClient) key(urlStr string) string {
+	sum := sha256.Sum256([]byte(urlStr))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *cacheClient) load(urlStr string) *cacheMeta {
+	data, err := os.ReadFile(c.key(urlStr) + ".json")
+	if err != nil {
+		return nil
+	}
+	meta := &cacheMeta{}
+	if err := json.Unmarshal(data, meta); err != nil || meta.URL != urlStr {
+		return nil
+	}
+	if _, err := os.Stat(c.key(urlStr) + ".body"); err != nil {
+		return nil
+	}
+	return meta
+}
+
+func (c *cacheClient) fresh(urlStr string) bool {
+	meta := c.load(urlStr)
+	return meta != nil && c.ttl > 0 && c.now().Sub(meta.Stored) < c.ttl
+}
+
+func (c *cacheClient) evict(urlStr string) {
+	c.remove(c.key(urlStr))
+}
+
+func (c *cacheClient) offline() bool {
+	next, ok := c.next.(offline)
+	return ok && next.offline()
+}
+
+func (c *cacheClient) buildRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	req, err := c.next.buildRequest(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if meta := c.load(urlStr); meta != nil {
+		if etag := meta.Header.Get("ETag"); len(etag) > 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := meta.Header.Get("Last-Modified"); len(modified) > 0 {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	return req, nil
+}
+
+func (c *cacheClient) sendRequest(req *http.Request) (*http.Response, error) {
+	urlStr := req.URL.String()
+	if c.fresh(urlStr) {
+		if res := c.response(req, c.load(urlStr)); res != nil {
+			return res, nil
+		}
+	}
+	res, err := c.next.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if meta := c.load(urlStr); meta != nil {
+			meta.Stored = c.now()
+			for _, name := range []string{"ETag", "Last-Modified"} {
+				if value := res.Header.Get(name); len(value) > 0 {
+					meta.Header.Set(name, value)
+				}
+			}
+			c.writeMeta(meta)
+			if cached := c.response(req, meta); cached != nil {
+				return cached, nil
+			}
+		}
+		// the response was removed in between, e.g. by a concurrent prune,
+		// so ask again without validators
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		res, err = c.next.sendRequest(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if res.StatusCode == http.StatusOK {
+		res.Body = &cacheWriter{
+			ReadCloser: res.Body,
+			client:     c,
+			meta:       &cacheMeta{URL: urlStr, Header: res.Header.Clone()},
+		}
+	}
+	return res, nil
+}
+
+func (c *cacheClient) getData(res *http.Response) ([]byte, error) {
+	if w, ok := res.Body.(*cacheWriter); ok {
+		w.record()
+	}
+	return c.next.getData(res)
+}
+
+func (c *cacheClient) getStream(res *http.Response) (io.ReadCloser, error) {
+	return c.next.getStream(res)
+}
+
+// response replays a stored response, its headers still carry the original
+// Content-Encoding so it is decoded like a response from the network.
+func (c *cacheClient) response(req *http.Request, meta *cacheMeta) *http.Response {
+	path := c.key(meta.URL) + ".body"
+	body, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	now := c.now()
+	os.Chtimes(path, now, now)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        meta.Header.Clone(),
+		Body:          body,
+		ContentLength: meta.Size,
+		Request:       req,
+	}
+}
+
+func (c *cacheClient) writeMeta(meta *cacheMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	tmp := c.key(meta.URL) + ".json.tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.key(meta.URL)+".json")
+}
+
+func (c *cacheClient) commit(tmp string, meta *cacheMeta) {
+	key := c.key(meta.URL)
+	old := int64(0)
+	if info, err := os.Stat(key + ".body"); err == nil {
+		old = info.Size()
+	}
+	meta.Stored = c.now()
+	if err := os.Rename(tmp, key+".body"); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := c.writeMeta(meta); err != nil {
+		c.remove(key)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scanned {
+		c.size += meta.Size - old
+	}
+	c.prune()
+}
+
+func (c *cacheClient) remove(key string) {
+	info, err := os.Stat(key + ".body")
+	os.Remove(key + ".json")
+	os.Remove(key + ".body")
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scanned {
+		c.size -= info.Size()
+	}
+}
+
+// prune removes the least recently used responses until the cache fits into
+// maxSize again, c.mu has to be held.
+func (c *cacheClient) prune() {
+	if c.maxSize <= 0 || (c.scanned && c.size <= c.maxSize) {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type body struct {
+		key  string
+		size int64
+		used time.Time
+	}
+	var bodies []body
+	var total int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".body")
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		bodies = append(bodies, body{key: filepath.Join(c.dir, name), size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(bodies, func(i, j int) bool { return bodies[i].used.Before(bodies[j].used) })
+	for _, b := range bodies {
+		if total <= c.maxSize {
+			break
+		}
+		os.Remove(b.key + ".json")
+		os.Remove(b.key + ".body")
+		total -= b.size
+	}
+	c.size = total
+	c.scanned = true
+}
+
+// cacheWriter copies a response body into the cache while it is read and
+// stores it once it was read completely, if record was called before. Bodies
+// closed early are dropped.
+type cacheWriter struct {
+	io.ReadCloser
+	tmp    *os.File
+	client *cacheClient
+	meta   *cacheMeta
+	failed bool
+	done   bool
+}
+
+func (w *cacheWriter) record() {
+	tmp, err := os.CreateTemp(w.client.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	w.tmp = tmp
+}
+
+func (w *cacheWriter) Read(p []byte) (int, error) {
+	n, err := w.ReadCloser.Read(p)
+	if w.tmp == nil {
+		return n, err
+	}
+	if n > 0 && !w.failed && !w.done {
+		if _, werr := w.tmp.Write(p[:n]); werr != nil {
+			w.failed = true
+		}
+		w.meta.Size += int64(n)
+	}
+	if err == io.EOF && !w.failed && !w.done {
+		w.done = true
+		if w.tmp.Close() == nil {
+			w.client.commit(w.tmp.Name(), w.meta)
+		} else {
+			os.Remove(w.tmp.Name())
+		}
+	}
+	return n, err
+}
+
+func (w *cacheWriter) Close() error {
+	if w.tmp != nil && !w.done {
+		w.done = true
+		w.tmp.Close()
+		os.Remove(w.tmp.Name())
+	}
+	return w.ReadCloser.Close()
+}
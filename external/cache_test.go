@@ -0,0 +1,175 @@
+package external
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCacheClient(t *testing.T, maxSize int64, ttl time.Duration) *cacheClient {
+	web, err := newWebClient(&Config{Name: "Example Corp", Email: "data@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCacheClient(web, t.TempDir(), maxSize, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func cacheGet(t *testing.T, c *cacheClient, urlStr string) string {
+	req, err := c.buildRequest(context.Background(), urlStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.getData(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCacheClientRevalidates(t *testing.T) {
+	var requests, conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("submissions"))
+	}))
+	defer server.Close()
+	c := newTestCacheClient(t, 0, 0)
+	for i := 0; i < 3; i++ {
+		if got := cacheGet(t, c, server.URL+"/CIK0000320193.json"); got != "submissions" {
+			t.Errorf("got %s, want submissions", got)
+		}
+	}
+	if requests != 3 || conditional != 2 {
+		t.Errorf("got %d requests of which %d conditional, want 3 and 2", requests, conditional)
+	}
+}
+
+func TestCacheClientRemovedBeforeNotModified(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("submissions"))
+	}))
+	defer server.Close()
+	c := newTestCacheClient(t, 0, 0)
+	urlStr := server.URL + "/CIK0000320193.json"
+	cacheGet(t, c, urlStr)
+	req, err := c.buildRequest(context.Background(), urlStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.evict(urlStr)
+	res, err := c.sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.getData(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "submissions" || requests != 3 {
+		t.Errorf("got %s after %d requests, want submissions after 3", data, requests)
+	}
+}
+
+func TestCacheClientTTL(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("index"))
+	}))
+	defer server.Close()
+	c := newTestCacheClient(t, 0, time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	cacheGet(t, c, server.URL+"/index.json")
+	if !c.fresh(server.URL + "/index.json") {
+		t.Errorf("response not fresh right after it was cached")
+	}
+	if got := cacheGet(t, c, server.URL+"/index.json"); got != "index" || requests != 1 {
+		t.Errorf("got %s after %d requests, want index after 1", got, requests)
+	}
+	now = now.Add(2 * time.Hour)
+	cacheGet(t, c, server.URL+"/index.json")
+	if requests != 2 {
+		t.Errorf("got %d requests, want stale response to be requested again", requests)
+	}
+	c.evict(server.URL + "/index.json")
+	if c.fresh(server.URL + "/index.json") {
+		t.Errorf("response still cached after it was evicted")
+	}
+}
+
+func TestCacheClientSkipsStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(testDocument)
+	}))
+	defer server.Close()
+	c := newTestCacheClient(t, 0, time.Hour)
+	req, _ := c.buildRequest(context.Background(), server.URL+"/main.htm")
+	res, err := c.sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := c.getStream(res)
+	io.Copy(io.Discard, body)
+	body.Close()
+	if c.load(server.URL+"/main.htm") != nil {
+		t.Errorf("streamed response was cached")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(c.dir, "tmp-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("got temporary files %v after close", leftovers)
+	}
+}
+
+func TestCacheClientPrune(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 100))
+	}))
+	defer server.Close()
+	c := newTestCacheClient(t, 250, time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	for _, name := range []string{"/a", "/b", "/c"} {
+		cacheGet(t, c, server.URL+name)
+		os.Chtimes(c.key(server.URL+name)+".body", now, now)
+		now = now.Add(time.Minute)
+	}
+	if c.load(server.URL+"/a") != nil {
+		t.Errorf("least recently used response was kept")
+	}
+	for _, name := range []string{"/b", "/c"} {
+		if c.load(server.URL+name) == nil {
+			t.Errorf("response %s was removed", name)
+		}
+	}
+	if c.size != 200 {
+		t.Errorf("got cache size %d, want 200", c.size)
+	}
+}
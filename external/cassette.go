@@ -0,0 +1,226 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+var ErrNotRecorded = errors.New("Response not recorded")
+
+// offline is implemented by clients which never send requests to EDGAR, so
+// the API does not wait on the rate limiter for them.
+type offline interface {
+	offline() bool
+}
+
+type interaction struct {
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// cassetteClient records every response to a directory, one file per
+// request, or replays them from there without a network. Requests to the
+// same URL are numbered, so retries replay in the order they were recorded.
+type cassetteClient struct {
+	next   client
+	dir    string
+	replay bool
+
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func newCassetteClient(next client, dir string, mode string) (*cassetteClient, error) {
+	switch mode {
+	case CassetteRecord:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.New("Could not create cassette directory, " + err.Error())
+		}
+	case CassetteReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, errors.New("Could not open cassette directory, " + err.Error())
+		}
+	default:
+		return nil, fmt.Errorf("Cassette mode must be '%s' or '%s', got '%s'", CassetteRecord, CassetteReplay, mode)
+	}
+	return &cassetteClient{next: next, dir: dir, replay: mode == CassetteReplay, count: make(map[string]int)}, nil
+}
+
+func (c *cassetteClient) offline() bool {
+	return c.replay
+}
+
+// path names the file of the n-th request to a URL after the URL itself, so a
+// cassette can be browsed, with a hash keeping long URLs apart.
+func (c *cassetteClient) path(urlStr string, n int) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(strings.TrimPrefix(urlStr, "https://"), "http://"))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	sum := sha256.Sum256([]byte(urlStr))
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%s-%03d.json", name, hex.EncodeToString(sum[:4]), n))
+}
+
+// sequence counts the requests to a URL.
+func (c *cassetteClient) sequence(urlStr string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.count[urlStr]
+	c.count[urlStr] = n + 1
+	return n
+}
+
+func (c *cassetteClient) buildRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	return c.next.buildRequest(ctx, urlStr)
+}
+
+func (c *cassetteClient) sendRequest(req *http.Request) (*http.Response, error) {
+	urlStr := req.URL.String()
+	path := c.path(urlStr, c.sequence(urlStr))
+	if c.replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w, no cassette entry '%s' for '%s'", ErrNotRecorded, filepath.Base(path), urlStr)
+		}
+		rec := &interaction{}
+		if err := json.Unmarshal(data, rec); err != nil {
+			return nil, fmt.Errorf("%w, cassette entry '%s', %s", ErrMalformed, filepath.Base(path), err.Error())
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+			StatusCode:    rec.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        rec.Header,
+			Body:          io.NopCloser(bytes.NewReader(rec.Body)),
+			ContentLength: int64(len(rec.Body)),
+			Request:       req,
+		}, nil
+	}
+	res, err := c.next.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	// the body is kept as sent, still compressed, so replaying decodes it
+	// exactly like the original response
+	data, err := json.MarshalIndent(&interaction{URL: urlStr, Status: res.StatusCode, Header: res.Header, Body: []byte{}}, "", "\t")
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	// the body is the last field, it is written between the quotes of its
+	// empty value
+	split := bytes.LastIndex(data, []byte(`""`)) + 1
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		res.Body.Close()
+		return nil, errors.New("Could not record response, " + err.Error())
+	}
+	if _, err := tmp.Write(data[:split]); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		res.Body.Close()
+		return nil, errors.New("Could not record response, " + err.Error())
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, tmp)
+	res.Body = &cassetteWriter{
+		ReadCloser: res.Body,
+		body:       io.TeeReader(res.Body, enc),
+		enc:        enc,
+		tmp:        tmp,
+		path:       path,
+		suffix:     data[split:],
+	}
+	return res, nil
+}
+
+func (c *cassetteClient) getData(res *http.Response) ([]byte, error) {
+	return c.next.getData(res)
+}
+
+func (c *cassetteClient) getStream(res *http.Response) (io.ReadCloser, error) {
+	return c.next.getStream(res)
+}
+
+// cassetteWriter streams a response body into its cassette entry while it is
+// read, so documents are not held in memory. The entry is stored once the
+// body was read completely, bodies closed early are read to the end first so
+// they replay in full.
+type cassetteWriter struct {
+	io.ReadCloser
+	body   io.Reader
+	enc    io.WriteCloser
+	tmp    *os.File
+	path   string
+	suffix []byte
+	done   bool
+}
+
+func (w *cassetteWriter) Read(p []byte) (int, error) {
+	n, err := w.body.Read(p)
+	if err == nil || w.done {
+		return n, err
+	}
+	w.done = true
+	if err != io.EOF {
+		w.tmp.Close()
+		os.Remove(w.tmp.Name())
+		return n, err
+	}
+	if err := w.commit(); err != nil {
+		return n, errors.New("Could not record response, " + err.Error())
+	}
+	return n, io.EOF
+}
+
+func (w *cassetteWriter) commit() error {
+	err := w.enc.Close()
+	if err == nil {
+		_, err = w.tmp.Write(w.suffix)
+	}
+	if closeErr := w.tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(w.tmp.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(w.tmp.Name())
+	}
+	return err
+}
+
+func (w *cassetteWriter) Close() error {
+	if !w.done {
+		if _, err := io.Copy(io.Discard, w); err != nil {
+			w.ReadCloser.Close()
+			return err
+		}
+	}
+	return w.ReadCloser.Close()
+}
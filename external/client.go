@@ -1,49 +1,230 @@
 package external
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type client interface {
-	buildRequest(urlStr string) (*http.Request, error)
+	buildRequest(ctx context.Context, urlStr string) (*http.Request, error)
 	sendRequest(req *http.Request) (*http.Response, error)
 	getData(res *http.Response) ([]byte, error)
+	getStream(res *http.Response) (io.ReadCloser, error)
 }
 
-type webClient struct{}
+type Config struct {
+	Name         string
+	Email        string
+	Timeout      time.Duration
+	TotalTimeout time.Duration
+	Proxy        string
+	MaxIdleConns int
+	CacheDir     string
+	CacheMaxSize int64
+	CacheTTL     time.Duration
+	CassetteDir  string
+	CassetteMode string
+	// Retries of transient failures and the delay before the first one,
+	// zero values keep the defaults.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
 
-func newWebClient() *webClient {
-	return &webClient{}
+	// Base URLs of the EDGAR endpoints, empty ones default to the SEC servers.
+	ArchivesURL    string
+	SubmissionsURL string
+	TickersURL     string
+	CurrentURL     string
 }
 
-func (c *webClient) buildRequest(urlStr string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", urlStr, nil)
+func (c *Config) validate() error {
+	if len(strings.TrimSpace(c.Name)) < 1 {
+		return errors.New("SEC fair access policy requires a company name in the User-Agent")
+	}
+	if !strings.Contains(c.Email, "@") {
+		return errors.New(fmt.Sprintf("SEC fair access policy requires a contact email, got '%s'", c.Email))
+	}
+	return nil
+}
+
+type webClient struct {
+	userAgent string
+	client    *http.Client
+	// timeout bounds the wait for the response headers and for every read
+	// of the body, not the whole download, so large documents which keep
+	// streaming are not cut off
+	timeout time.Duration
+}
+
+func newWebClient(cfg *Config) (*webClient, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	proxy := http.ProxyFromEnvironment
+	if len(cfg.Proxy) > 0 {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, errors.New("Could not parse proxy URL, " + err.Error())
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle < 1 {
+		maxIdle = 10
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdle,
+		MaxIdleConnsPerHost:   maxIdle,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.Timeout,
+		// Decompression is done in getData since we ask for deflate as well.
+		DisableCompression: true,
+	}
+	return &webClient{
+		userAgent: strings.TrimSpace(cfg.Name) + " " + strings.TrimSpace(cfg.Email),
+		client:    &http.Client{Transport: transport},
+		timeout:   cfg.Timeout,
+	}, nil
+}
+
+func (c *webClient) buildRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("User-Agent", "example.com info@example.com")
+	req.Header.Add("User-Agent", c.userAgent)
 	req.Header.Add("Accept", "*/*")
+	req.Header.Add("Accept-Encoding", "gzip, deflate")
 	req.Header.Add("Connection", "keep-alive")
 	return req, nil
 }
 
 func (c *webClient) sendRequest(req *http.Request) (*http.Response, error) {
-	time.Sleep(200 * time.Millisecond)
-	client := &http.Client{}
-	res, err := client.Do(req)
+	if c.timeout <= 0 {
+		return c.client.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	res, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	res.Body = &idleBody{
+		ReadCloser: res.Body,
+		timer:      time.AfterFunc(c.timeout, cancel),
+		timeout:    c.timeout,
+		cancel:     cancel,
+	}
 	return res, nil
 }
 
+// idleBody cancels its request once the body delivered no data for timeout,
+// so a stalled download fails while a slow one may take as long as it needs.
+type idleBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
 func (c *webClient) getData(res *http.Response) ([]byte, error) {
 	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
+	if err := checkResponse(res); err != nil {
+		io.Copy(io.Discard, res.Body)
+		return nil, err
+	}
+	body, err := decodeBody(res)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
+
+func (c *webClient) getStream(res *http.Response) (io.ReadCloser, error) {
+	if err := checkResponse(res); err != nil {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return nil, err
+	}
+	body, err := decodeBody(res)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return &stream{Reader: body, closers: []io.Closer{body, res.Body}}, nil
+}
+
+type stream struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (s *stream) Close() error {
+	var errs []error
+	for _, c := range s.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// decodeBody undoes the Content-Encoding of the response. Servers disagree on
+// whether deflate means zlib wrapped or raw deflate data, so both are tried.
+func decodeBody(res *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return io.NopCloser(res.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(res.Body)
+	case "deflate":
+		data, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return flate.NewReader(bytes.NewReader(data)), nil
+		}
+		return zr, nil
+	default:
+		return nil, fmt.Errorf(
+			"%w, unsupported content encoding '%s'",
+			ErrMalformed,
+			res.Header.Get("Content-Encoding"),
+		)
+	}
+}
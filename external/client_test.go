@@ -0,0 +1,184 @@
+package external
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewWebClient(t *testing.T) {
+	var tests = []struct {
+		name  string
+		cfg   *Config
+		valid bool
+	}{
+		{"Valid identity", &Config{Name: "Example Corp", Email: "data@example.com"}, true},
+		{"Missing name", &Config{Email: "data@example.com"}, false},
+		{"Missing email", &Config{Name: "Example Corp"}, false},
+		{"Invalid email", &Config{Name: "Example Corp", Email: "example.com"}, false},
+		{
+			"Invalid proxy",
+			&Config{Name: "Example Corp", Email: "data@example.com", Proxy: "://proxy"},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newWebClient(test.cfg)
+			if test.valid && err != nil {
+				t.Errorf(err.Error())
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected an error thrown")
+			}
+		})
+	}
+}
+
+func TestWebClientGetData(t *testing.T) {
+	content := []byte("UNITED STATES SECURITIES AND EXCHANGE COMMISSION")
+	var gzipped, zlibbed, deflated bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(content)
+	gw.Close()
+	zw := zlib.NewWriter(&zlibbed)
+	zw.Write(content)
+	zw.Close()
+	fw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	fw.Write(content)
+	fw.Close()
+	var tests = []struct {
+		name     string
+		status   int
+		encoding string
+		body     []byte
+		err      error
+	}{
+		{"Plain body", 200, "", content, nil},
+		{"Gzip body", 200, "gzip", gzipped.Bytes(), nil},
+		{"Zlib deflate body", 200, "deflate", zlibbed.Bytes(), nil},
+		{"Raw deflate body", 200, "deflate", deflated.Bytes(), nil},
+		{"Unknown encoding", 200, "br", content, ErrMalformed},
+		{"Not found", 404, "", content, ErrNotFound},
+		{"Rate limited", 429, "", content, ErrRateLimited},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var userAgent, acceptEncoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userAgent = r.Header.Get("User-Agent")
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				if len(test.encoding) > 0 {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				w.WriteHeader(test.status)
+				w.Write(test.body)
+			}))
+			defer server.Close()
+			c, err := newWebClient(&Config{Name: "Example Corp", Email: "data@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := c.buildRequest(context.Background(), server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := c.sendRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.getData(res)
+			if userAgent != "Example Corp data@example.com" {
+				t.Errorf("got User-Agent %s, want Example Corp data@example.com", userAgent)
+			}
+			if acceptEncoding != "gzip, deflate" {
+				t.Errorf("got Accept-Encoding %s, want gzip, deflate", acceptEncoding)
+			}
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("got error %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf(err.Error())
+				return
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("got %s, want %s", string(got), string(content))
+			}
+		})
+	}
+}
+
+func TestDecodeBodyIdentity(t *testing.T) {
+	res := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader([]byte("test")))}
+	res.Header.Set("Content-Encoding", "identity")
+	body, err := decodeBody(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(body)
+	if string(got) != "test" {
+		t.Errorf("got %s, want test", string(got))
+	}
+}
+
+func TestWebClientTimeout(t *testing.T) {
+	var tests = []struct {
+		name  string
+		pause time.Duration
+		valid bool
+	}{
+		{"Slow body longer than the timeout", 30 * time.Millisecond, true},
+		{"Stalled body", 300 * time.Millisecond, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 8; i++ {
+					w.Write([]byte("chunk"))
+					w.(http.Flusher).Flush()
+					select {
+					case <-time.After(test.pause):
+					case <-r.Context().Done():
+						return
+					}
+				}
+			}))
+			defer server.Close()
+			c, err := newWebClient(&Config{Name: "Example Corp", Email: "data@example.com", Timeout: 100 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := c.buildRequest(context.Background(), server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := c.sendRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := c.getStream(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			data, err := io.ReadAll(body)
+			if test.valid && (err != nil || len(data) != 40) {
+				t.Errorf("got %d bytes and error %v, want the whole body", len(data), err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected the stalled body to time out")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package external
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Company struct {
+	CIK                  string
+	Name                 string
+	EntityType           string
+	SIC                  string
+	SICDescription       string
+	Tickers              []string
+	Exchanges            []string
+	EIN                  string
+	Category             string
+	FiscalYearEnd        string
+	StateOfIncorporation string
+	Phone                string
+	BusinessAddress      *Address
+	MailAddress          *Address
+	FormerNames          []*FormerName
+}
+
+type FormerName struct {
+	Name string
+	From sql.NullTime
+	To   sql.NullTime
+}
+
+// Submissions is everything read from the submissions JSON of a company.
+type Submissions struct {
+	Company *Company
+	Filings []*Filing
+	Skipped []*Skipped
+	// Rows of the submissions JSON which failed validation.
+	Rejected []*Rejected
+}
+
+// transform adds the filings of a page of the submissions JSON. Validation
+// rejects the rows transformFilings cannot handle, should one slip through
+// the page is reported as malformed instead of crashing the run.
+func (s *Submissions) transform(data *recent, policy *FormPolicy) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w, could not transform submissions, %v", ErrMalformed, r)
+		}
+	}()
+	filings, skipped, rejected := transformFilings(data, policy)
+	s.Filings = append(s.Filings, filings...)
+	s.Skipped = append(s.Skipped, skipped...)
+	s.Rejected = append(s.Rejected, rejected...)
+	return nil
+}
+
+func transformCompany(data *filingsResponse) *Company {
+	cmp := &Company{
+		CIK:                  data.CIK,
+		Name:                 data.Name,
+		EntityType:           data.EntityType,
+		SIC:                  data.SIC,
+		SICDescription:       data.SICDescription,
+		Tickers:              data.Tickers,
+		Exchanges:            data.Exchanges,
+		EIN:                  data.EIN,
+		Category:             data.Category,
+		FiscalYearEnd:        data.FiscalYearEnd,
+		StateOfIncorporation: data.StateOfIncorporation,
+		Phone:                data.Phone,
+		BusinessAddress:      transformCompanyAddress(data.Addresses.Business),
+		MailAddress:          transformCompanyAddress(data.Addresses.Mailing),
+	}
+	for _, v := range data.FormerNames {
+		cmp.FormerNames = append(cmp.FormerNames, &FormerName{
+			Name: v.Name,
+			From: parseNullTime(time.RFC3339, v.From),
+			To:   parseNullTime(time.RFC3339, v.To),
+		})
+	}
+	return cmp
+}
+
+func transformCompanyAddress(addr *address) *Address {
+	if addr == nil {
+		return nil
+	}
+	return &Address{
+		Street1: addr.Street1,
+		Street2: addr.Street2,
+		City:    addr.City,
+		State:   addr.StateOrCountry,
+		Zip:     addr.ZipCode,
+	}
+}
@@ -0,0 +1,58 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DocumentFilter selects which files of a filing besides the primary document
+// get archived. A nil filter archives only the primary document.
+type DocumentFilter struct {
+	All      bool
+	Patterns []string
+}
+
+// ParseDocumentFilter accepts "main", "all" or a comma separated list of glob
+// patterns like "*.htm,EX-*,R*.htm" which are matched case insensitively.
+func ParseDocumentFilter(value string) (*DocumentFilter, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "main":
+		return nil, nil
+	case "all":
+		return &DocumentFilter{All: true}, nil
+	}
+	filter := &DocumentFilter{}
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if len(pattern) < 1 {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid document pattern '%s'", pattern))
+		}
+		filter.Patterns = append(filter.Patterns, pattern)
+	}
+	if len(filter.Patterns) < 1 {
+		return nil, errors.New("Document filter must contain at least one pattern")
+	}
+	return filter, nil
+}
+
+func (f *DocumentFilter) Match(name string) bool {
+	if f == nil {
+		return false
+	}
+	if f.All {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range f.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package external
+
+import "testing"
+
+func TestDocumentFilter(t *testing.T) {
+	var tests = []struct {
+		name    string
+		filter  string
+		valid   bool
+		matches []string
+		misses  []string
+	}{
+		{"Main only", "main", true, nil, []string{"ex21.htm", "aapl-20230930.htm"}},
+		{"Empty means main only", "", true, nil, []string{"ex21.htm"}},
+		{"All", "ALL", true, []string{"ex21.htm", "R1.htm", "logo.jpg"}, nil},
+		{
+			"Patterns",
+			"*.xml, r*.htm",
+			true,
+			[]string{"aapl-20230930_htm.xml", "R12.htm", "FilingSummary.xml"},
+			[]string{"ex21.htm", "logo.jpg"},
+		},
+		{"Invalid pattern", "[", false, nil, nil},
+		{"Only separators", ", ,", false, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := ParseDocumentFilter(test.filter)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("expected an error thrown")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf(err.Error())
+				return
+			}
+			for _, name := range test.matches {
+				if !filter.Match(name) {
+					t.Errorf("expected %s to match", name)
+				}
+			}
+			for _, name := range test.misses {
+				if filter.Match(name) {
+					t.Errorf("expected %s not to match", name)
+				}
+			}
+		})
+	}
+}
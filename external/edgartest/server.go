@@ -0,0 +1,345 @@
+// Package edgartest provides a fake EDGAR for integration tests. It serves
+// submissions, filing indexes and documents from in-memory fixtures and can
+// inject the failures seen from the real SEC servers.
+package edgartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/external"
+)
+
+type Company struct {
+	CIK     string
+	Name    string
+	Tickers []string
+	Filings []*Filing
+}
+
+type Filing struct {
+	AccessionNumber    string
+	Form               string
+	FilingDate         string
+	ReportDate         string
+	AcceptanceDateTime string
+	PrimaryDocument    string
+	IsXBRL             bool
+	// Documents by file name, the primary document has to be one of them.
+	Documents map[string][]byte
+}
+
+// Fault replaces the responses to requests whose path starts with a prefix.
+type Fault struct {
+	// Status is sent instead of the fixture, e.g. 429, 404 or 503.
+	Status     int
+	RetryAfter string
+	Latency    time.Duration
+	// Malformed sends truncated JSON, BlockPage the SEC's block page, both
+	// with status 200.
+	Malformed bool
+	BlockPage bool
+	// Times is the number of requests the fault applies to, 0 for all.
+	Times int
+}
+
+type fault struct {
+	prefix string
+	Fault
+	hits int
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	companies map[string]*Company
+	faults    []*fault
+	latency   time.Duration
+	requests  map[string]int
+}
+
+// BlockPageBody is what EDGAR serves undeclared automated tools.
+const BlockPageBody = `<!DOCTYPE html>
+<html>
+<head><title>SEC.gov | Request Rate Threshold Exceeded</title></head>
+<body>
+<h1>Your Request Originates from an Undeclared Automated Tool</h1>
+<p>To allow for equitable access to all users, SEC reserves the right to limit requests originating from undeclared automated tools.</p>
+<p>Please declare your traffic by updating your user agent to include company specific information.</p>
+</body>
+</html>
+`
+
+func NewServer() *Server {
+	s := &Server{companies: make(map[string]*Company), requests: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Configure points an API configuration at the server.
+func (s *Server) Configure(cfg *external.Config) {
+	cfg.ArchivesURL = s.URL + "/Archives/edgar/"
+	cfg.SubmissionsURL = s.URL + "/submissions/"
+	cfg.TickersURL = s.URL + "/files/company_tickers_exchange.json"
+	cfg.CurrentURL = s.URL + "/cgi-bin/browse-edgar?action=getcurrent&owner=include&output=atom"
+}
+
+// AddCompany adds or replaces a company with all its filings.
+func (s *Server) AddCompany(cmp *Company) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.companies[strings.TrimLeft(cmp.CIK, "0")] = cmp
+}
+
+// AddFiling adds a filing to a company added before, newest filings go
+// first like in the submissions JSON.
+func (s *Server) AddFiling(cik string, fil *Filing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmp := s.companies[strings.TrimLeft(cik, "0")]
+	cmp.Filings = append([]*Filing{fil}, cmp.Filings...)
+}
+
+// AddFault injects a fault into the responses to paths starting with prefix,
+// e.g. "/submissions/" or "/Archives/edgar/data/320193/".
+func (s *Server) AddFault(prefix string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{prefix: prefix, Fault: f})
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetLatency delays every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests counts the requests to paths starting with prefix.
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for p, count := range s.requests {
+		if strings.HasPrefix(p, prefix) {
+			n += count
+		}
+	}
+	return n
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	latency := s.latency
+	var active *fault
+	for _, f := range s.faults {
+		if strings.HasPrefix(r.URL.Path, f.prefix) && (f.Times < 1 || f.hits < f.Times) {
+			f.hits++
+			active = f
+			break
+		}
+	}
+	s.mu.Unlock()
+	if active != nil {
+		latency += active.Latency
+	}
+	time.Sleep(latency)
+	if !strings.Contains(r.Header.Get("User-Agent"), "@") {
+		s.blockPage(w, http.StatusForbidden)
+		return
+	}
+	if active != nil {
+		switch {
+		case active.BlockPage:
+			s.blockPage(w, http.StatusOK)
+			return
+		case active.Malformed:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"cik":"0000320193","filings":{"recent":{"accessionNumber":[`))
+			return
+		case active.Status > 0:
+			if len(active.RetryAfter) > 0 {
+				w.Header().Set("Retry-After", active.RetryAfter)
+			}
+			w.WriteHeader(active.Status)
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := r.URL.Path
+	switch {
+	case strings.HasPrefix(p, "/submissions/CIK") && strings.HasSuffix(p, ".json"):
+		s.submissions(w, strings.TrimSuffix(strings.TrimPrefix(p, "/submissions/CIK"), ".json"))
+	case strings.HasPrefix(p, "/Archives/edgar/data/"):
+		parts := strings.Split(strings.TrimPrefix(p, "/Archives/edgar/data/"), "/")
+		if len(parts) != 3 {
+			http.NotFound(w, r)
+			return
+		}
+		s.archive(w, r, parts[0], parts[1], parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) blockPage(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	w.Write([]byte(BlockPageBody))
+}
+
+func (s *Server) submissions(w http.ResponseWriter, cik string) {
+	cmp, ok := s.companies[strings.TrimLeft(cik, "0")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	recent := map[string][]interface{}{}
+	add := func(key string, value interface{}) {
+		recent[key] = append(recent[key], value)
+	}
+	for _, fil := range cmp.Filings {
+		add("accessionNumber", fil.AccessionNumber)
+		add("filingDate", fil.FilingDate)
+		add("reportDate", fil.ReportDate)
+		add("acceptanceDateTime", fil.AcceptanceDateTime)
+		add("form", fil.Form)
+		add("primaryDocument", fil.PrimaryDocument)
+		add("primaryDocDescription", fil.Form)
+		add("size", fil.size())
+		add("isXBRL", boolInt(fil.IsXBRL))
+		add("isInlineXBRL", boolInt(fil.IsXBRL))
+	}
+	writeJSON(w, map[string]interface{}{
+		"cik":     strings.TrimLeft(cmp.CIK, "0"),
+		"name":    cmp.Name,
+		"tickers": cmp.Tickers,
+		"filings": map[string]interface{}{"recent": recent, "files": []interface{}{}},
+	})
+}
+
+func (s *Server) archive(w http.ResponseWriter, r *http.Request, cik string, id string, name string) {
+	cmp, ok := s.companies[strings.TrimLeft(cik, "0")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var fil *Filing
+	for _, v := range cmp.Filings {
+		if strings.ReplaceAll(v.AccessionNumber, "-", "") == id {
+			fil = v
+		}
+	}
+	if fil == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if name == "index.json" {
+		var items []map[string]string
+		for _, docName := range fil.names() {
+			items = append(items, map[string]string{
+				"name":          docName,
+				"type":          "text.gif",
+				"size":          fmt.Sprint(len(fil.Documents[docName])),
+				"last-modified": "2023-11-02 18:08:27",
+			})
+		}
+		writeJSON(w, map[string]interface{}{"directory": map[string]interface{}{"item": items}})
+		return
+	}
+	if data, ok := fil.Documents[name]; ok {
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+		w.Write(data)
+		return
+	}
+	if name == fil.AccessionNumber+".txt" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(fil.submission(cmp))
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (f *Filing) names() []string {
+	names := make([]string, 0, len(f.Documents))
+	for name := range f.Documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *Filing) size() int {
+	size := 0
+	for _, data := range f.Documents {
+		size += len(data)
+	}
+	return size
+}
+
+// submission builds the complete submission text file of a filing.
+func (f *Filing) submission(cmp *Company) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<SEC-DOCUMENT>%s.txt : %s\n", f.AccessionNumber, strings.ReplaceAll(f.FilingDate, "-", ""))
+	fmt.Fprintf(&b, "<SEC-HEADER>%s.hdr.sgml : %s\n", f.AccessionNumber, strings.ReplaceAll(f.FilingDate, "-", ""))
+	fmt.Fprintf(&b, "ACCESSION NUMBER:\t\t%s\n", f.AccessionNumber)
+	fmt.Fprintf(&b, "CONFORMED SUBMISSION TYPE:\t%s\n", f.Form)
+	fmt.Fprintf(&b, "PUBLIC DOCUMENT COUNT:\t\t%d\n", len(f.Documents))
+	fmt.Fprintf(&b, "CONFORMED PERIOD OF REPORT:\t%s\n", strings.ReplaceAll(f.ReportDate, "-", ""))
+	fmt.Fprintf(&b, "FILED AS OF DATE:\t\t%s\n", strings.ReplaceAll(f.FilingDate, "-", ""))
+	fmt.Fprintf(&b, "\nFILER:\n\n\tCOMPANY DATA:\n\t\tCOMPANY CONFORMED NAME:\t\t\t%s\n", cmp.Name)
+	fmt.Fprintf(&b, "\t\tCENTRAL INDEX KEY:\t\t\t%s\n", cmp.CIK)
+	b.WriteString("</SEC-HEADER>\n")
+	names := f.names()
+	// the primary document is always the first one
+	sort.SliceStable(names, func(i, j int) bool { return names[i] == f.PrimaryDocument })
+	for i, name := range names {
+		docType := "EX-99"
+		if name == f.PrimaryDocument {
+			docType = f.Form
+		}
+		fmt.Fprintf(&b, "<DOCUMENT>\n<TYPE>%s\n<SEQUENCE>%d\n<FILENAME>%s\n<TEXT>\n", docType, i+1, name)
+		b.Write(f.Documents[name])
+		if !strings.HasSuffix(string(f.Documents[name]), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("</TEXT>\n</DOCUMENT>\n")
+	}
+	b.WriteString("</SEC-DOCUMENT>\n")
+	return []byte(b.String())
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
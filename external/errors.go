@@ -0,0 +1,132 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("Resource not found")
+	ErrRateLimited = errors.New("Rate limited by EDGAR")
+	ErrServerError = errors.New("EDGAR server error")
+	ErrMalformed   = errors.New("Malformed response")
+	ErrUnexpected  = errors.New("Unexpected response status")
+)
+
+type ResponseError struct {
+	URL        string
+	Status     int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s, status %d for '%s'", e.Err.Error(), e.Status, e.URL)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// checkResponse returns nil for successful responses and a ResponseError
+// wrapping one of the error kinds above otherwise.
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	resErr := &ResponseError{Status: res.StatusCode}
+	if res.Request != nil && res.Request.URL != nil {
+		resErr.URL = res.Request.URL.String()
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		resErr.Err = ErrNotFound
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusForbidden:
+		resErr.Err = ErrRateLimited
+		resErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	case res.StatusCode >= 500:
+		resErr.Err = ErrServerError
+		resErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	default:
+		resErr.Err = ErrUnexpected
+	}
+	return resErr
+}
+
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if len(value) < 1 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	t, err := http.ParseTime(value)
+	if err != nil || t.Before(now) {
+		return 0
+	}
+	return t.Sub(now)
+}
+
+func malformed(structName string, err error) error {
+	return fmt.Errorf("%w, could not process JSON into struct %s, %s", ErrMalformed, structName, err.Error())
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServerError) || errors.Is(err, ErrBlockPage) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type retryPolicy struct {
+	attempts int
+	base     time.Duration
+	max      time.Duration
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{attempts: 5, base: time.Second, max: time.Minute}
+}
+
+// backoff doubles the base delay for every attempt, picks a random delay in
+// the upper half of it and never waits less than the server asked for.
+func (p retryPolicy) backoff(attempt int, err error) time.Duration {
+	delay := p.base << attempt
+	if delay > p.max || delay <= 0 {
+		delay = p.max
+	}
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	if wait := retryAfter(err); wait > delay {
+		delay = wait
+	}
+	return delay
+}
+
+// retryAfter returns the delay the server asked for with Retry-After.
+func retryAfter(err error) time.Duration {
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		return resErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package external
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCheckResponse(t *testing.T) {
+	var tests = []struct {
+		name       string
+		status     int
+		retryAfter string
+		err        error
+		wait       time.Duration
+	}{
+		{"OK", 200, "", nil, 0},
+		{"Not found", 404, "", ErrNotFound, 0},
+		{"Gone", 410, "", ErrNotFound, 0},
+		{"Too many requests", 429, "", ErrRateLimited, 0},
+		{"Too many requests with Retry-After", 429, "10", ErrRateLimited, 10 * time.Second},
+		{"Forbidden block", 403, "", ErrRateLimited, 0},
+		{"Service unavailable", 503, "120", ErrServerError, 2 * time.Minute},
+		{"Internal server error", 500, "", ErrServerError, 0},
+		{"Bad request", 400, "", ErrUnexpected, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := &http.Request{URL: &url.URL{Scheme: "https", Host: "data.sec.gov", Path: "/test"}}
+			res := &http.Response{StatusCode: test.status, Header: http.Header{}, Request: req}
+			if len(test.retryAfter) > 0 {
+				res.Header.Set("Retry-After", test.retryAfter)
+			}
+			err := checkResponse(res)
+			if test.err == nil {
+				if err != nil {
+					t.Errorf(err.Error())
+				}
+				return
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+				return
+			}
+			var resErr *ResponseError
+			if !errors.As(err, &resErr) {
+				t.Errorf("expected a ResponseError, but got: %v", err)
+				return
+			}
+			if resErr.RetryAfter != test.wait {
+				t.Errorf("got Retry-After %s, want %s", resErr.RetryAfter, test.wait)
+			}
+			if resErr.URL != "https://data.sec.gov/test" {
+				t.Errorf("got URL %s, want https://data.sec.gov/test", resErr.URL)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"Empty", "", 0},
+		{"Seconds", "30", 30 * time.Second},
+		{"Negative seconds", "-30", 0},
+		{"HTTP date", "Mon, 01 Jan 2024 12:01:00 GMT", time.Minute},
+		{"HTTP date in the past", "Mon, 01 Jan 2024 11:00:00 GMT", 0},
+		{"Garbage", "soon", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseRetryAfter(test.value, now)
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := retryPolicy{attempts: 5, base: time.Second, max: 10 * time.Second}
+	var tests = []struct {
+		name    string
+		attempt int
+		err     error
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"First attempt", 0, ErrServerError, 500 * time.Millisecond, time.Second},
+		{"Third attempt", 2, ErrServerError, 2 * time.Second, 4 * time.Second},
+		{"Capped by max", 10, ErrServerError, 5 * time.Second, 10 * time.Second},
+		{
+			"Retry-After wins",
+			0,
+			&ResponseError{Err: ErrRateLimited, RetryAfter: 30 * time.Second},
+			30 * time.Second,
+			30 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				got := policy.backoff(test.attempt, test.err)
+				if got < test.min || got > test.max {
+					t.Errorf("got %s, want between %s and %s", got, test.min, test.max)
+					return
+				}
+			}
+		})
+	}
+}
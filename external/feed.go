@@ -0,0 +1,112 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FeedEntry is a filing from the EDGAR latest filings Atom feed. A filing
+// with several filers is listed once for every one of them.
+type FeedEntry struct {
+	CIK             string
+	CompanyName     string
+	Role            string
+	Form            string
+	AccessionNumber string
+	AcceptDate      sql.NullTime
+}
+
+func (e *FeedEntry) GetID() string {
+	return strings.Replace(e.AccessionNumber, "-", "", -1)
+}
+
+// GetCurrentFilings reads a page of the latest filings feed, newest first.
+func (api *API) GetCurrentFilings(ctx context.Context, start int, count int) ([]*FeedEntry, error) {
+	data, err := api.fetch(ctx, fmt.Sprintf("%s&start=%d&count=%d", api.currentURL, start, count))
+	if err != nil {
+		return nil, err
+	}
+	return parseFeed(data)
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title    string `xml:"title"`
+	Updated  string `xml:"updated"`
+	ID       string `xml:"id"`
+	Category struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+// the title reads "10-K - Apple Inc. (0000320193) (Filer)"
+var feedTitle = regexp.MustCompile(`^(.*) - (.*) \((\d{1,10})\) \(([^)]*)\)$`)
+
+func parseFeed(data []byte) ([]*FeedEntry, error) {
+	feed := &atomFeed{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(feed); err != nil {
+		return nil, fmt.Errorf("%w, could not process XML into struct atomFeed, %s", ErrMalformed, err.Error())
+	}
+	entries := make([]*FeedEntry, 0, len(feed.Entries))
+	for _, v := range feed.Entries {
+		match := feedTitle.FindStringSubmatch(strings.TrimSpace(v.Title))
+		if match == nil {
+			return nil, fmt.Errorf("%w, unexpected feed entry title '%s'", ErrMalformed, v.Title)
+		}
+		_, accession, ok := strings.Cut(v.ID, "accession-number=")
+		if !ok {
+			return nil, fmt.Errorf("%w, feed entry '%s' has no accession number", ErrMalformed, v.ID)
+		}
+		cik, err := NormalizeCIK(match[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s", ErrMalformed, err.Error())
+		}
+		form := v.Category.Term
+		if len(form) < 1 {
+			form = match[1]
+		}
+		entries = append(entries, &FeedEntry{
+			CIK:             cik,
+			CompanyName:     match[2],
+			Role:            match[4],
+			Form:            form,
+			AccessionNumber: accession,
+			AcceptDate:      parseNullTime(time.RFC3339, strings.TrimSpace(v.Updated)),
+		})
+	}
+	return entries, nil
+}
+
+// charsetReader decodes the ISO-8859-1 EDGAR declares for its feeds, every
+// byte of which is the code point of the same value.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "us-ascii":
+		var out bytes.Buffer
+		r := bufio.NewReader(input)
+		for {
+			b, err := r.ReadByte()
+			if err == io.EOF {
+				return &out, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			out.WriteRune(rune(b))
+		}
+	}
+	return nil, fmt.Errorf("unsupported charset '%s'", charset)
+}
@@ -0,0 +1,91 @@
+package external
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var testFeed = []byte(`<?xml version="1.0" encoding="ISO-8859-1" ?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Latest Filings - Thu, 02 Nov 2023 18:10:05 EDT</title>
+<updated>2023-11-02T18:10:05-04:00</updated>
+<entry>
+<title>10-K - Apple Inc. (0000320193) (Filer)</title>
+<link rel="alternate" type="text/html" href="https://www.sec.gov/Archives/edgar/data/320193/000032019323000106/0000320193-23-000106-index.htm"/>
+<summary type="html"> &lt;b&gt;Filed:&lt;/b&gt; 2023-11-03 &lt;b&gt;AccNo:&lt;/b&gt; 0000320193-23-000106 &lt;b&gt;Size:&lt;/b&gt; 9 MB</summary>
+<updated>2023-11-02T18:08:27-04:00</updated>
+<category scheme="https://www.sec.gov/" label="form type" term="10-K"/>
+<id>urn:tag:sec.gov,2008:accession-number=0000320193-23-000106</id>
+</entry>
+<entry>
+<title>SC 13G/A - Soci` + "\xe9" + `t` + "\xe9" + ` Example (Two) Corp (0001234567) (Subject)</title>
+<updated>2023-11-02T18:05:00-04:00</updated>
+<category scheme="https://www.sec.gov/" label="form type" term="SC 13G/A"/>
+<id>urn:tag:sec.gov,2008:accession-number=0000950123-23-000001</id>
+</entry>
+</feed>
+`)
+
+func TestParseFeed(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input []byte
+		err   error
+		want  []FeedEntry
+	}{
+		{
+			"Latest filings",
+			testFeed,
+			nil,
+			[]FeedEntry{
+				{
+					CIK:             "0000320193",
+					CompanyName:     "Apple Inc.",
+					Role:            "Filer",
+					Form:            "10-K",
+					AccessionNumber: "0000320193-23-000106",
+				},
+				{
+					CIK:             "0001234567",
+					CompanyName:     "Société Example (Two) Corp",
+					Role:            "Subject",
+					Form:            "SC 13G/A",
+					AccessionNumber: "0000950123-23-000001",
+				},
+			},
+		},
+		{"Empty feed", []byte(`<feed xmlns="http://www.w3.org/2005/Atom"></feed>`), nil, []FeedEntry{}},
+		{
+			"Missing accession number",
+			[]byte(`<feed><entry><title>10-K - Apple Inc. (0000320193) (Filer)</title><id>x</id></entry></feed>`),
+			errors.New(""),
+			nil,
+		},
+		{"Malformed", []byte(`<feed><entry>`), errors.New(""), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseFeed(test.input)
+			if (err != nil) != (test.err != nil) {
+				t.Errorf("got error %v, want error %v", err, test.err)
+				return
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("got %d entries, want %d", len(got), len(test.want))
+				return
+			}
+			for i, v := range got {
+				v.AcceptDate.Valid, v.AcceptDate.Time = false, time.Time{}
+				if *v != test.want[i] {
+					t.Errorf("got %+v, want %+v", *v, test.want[i])
+				}
+			}
+		})
+	}
+	got, _ := parseFeed(testFeed)
+	want := time.Date(2023, time.November, 2, 22, 8, 27, 0, time.UTC)
+	if !got[0].AcceptDate.Valid || !got[0].AcceptDate.Time.Equal(want) {
+		t.Errorf("got acceptance %s, want %s", got[0].AcceptDate.Time, want)
+	}
+}
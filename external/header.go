@@ -0,0 +1,289 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type Header struct {
+	AccessionNumber    string
+	Type               string
+	PeriodOfReport     sql.NullTime
+	FilingDate         sql.NullTime
+	AcceptanceDateTime sql.NullTime
+	Items              []string
+	Parties            []*HeaderParty
+}
+
+type HeaderParty struct {
+	Role                 string
+	Name                 string
+	CIK                  string
+	SIC                  string
+	IRSNumber            string
+	StateOfIncorporation string
+	FiscalYearEnd        string
+	FilerStatus          string
+	FormType             string
+	Act                  string
+	FileNumber           string
+	FilmNumber           string
+	BusinessAddress      *Address
+	MailAddress          *Address
+}
+
+type Address struct {
+	Street1 string
+	Street2 string
+	City    string
+	State   string
+	Zip     string
+	Phone   string
+}
+
+// textHeaderTags maps the keys of the header of a complete submission text
+// file to the tags of the SGML header, keys not listed only have their spaces
+// replaced.
+var textHeaderTags = map[string]string{
+	"CONFORMED SUBMISSION TYPE":          "TYPE",
+	"CONFORMED PERIOD OF REPORT":         "PERIOD",
+	"FILED AS OF DATE":                   "FILING-DATE",
+	"COMPANY CONFORMED NAME":             "CONFORMED-NAME",
+	"CENTRAL INDEX KEY":                  "CIK",
+	"STANDARD INDUSTRIAL CLASSIFICATION": "ASSIGNED-SIC",
+	"SEC ACT":                            "ACT",
+	"SEC FILE NUMBER":                    "FILE-NUMBER",
+	"STREET 1":                           "STREET1",
+	"STREET 2":                           "STREET2",
+	"BUSINESS PHONE":                     "PHONE",
+	"DATE OF NAME CHANGE":                "DATE-CHANGED",
+}
+
+var partyRoles = []string{"FILER", "FILED-BY", "SUBJECT-COMPANY", "REPORTING-OWNER", "ISSUER", "SERIAL-COMPANY"}
+
+// GetHeader reads the SGML header EDGAR keeps next to every accession.
+func (api *API) GetHeader(ctx context.Context, cik string, fil *Filing) (*Header, error) {
+	data, err := api.fetch(ctx, api.fileURL+cik+"/"+fil.GetID()+"/"+fil.secID+".hdr.sgml")
+	if err != nil {
+		return nil, err
+	}
+	return parseHeader(data)
+}
+
+func parseHeader(data []byte) (*Header, error) {
+	root, err := parseSGML(data)
+	if err != nil {
+		return nil, err
+	}
+	secHeader := root.child("SEC-HEADER")
+	if secHeader == nil {
+		return nil, fmt.Errorf("%w, header has no SEC-HEADER", ErrMalformed)
+	}
+	hdr := &Header{
+		AccessionNumber:    secHeader.value("ACCESSION-NUMBER"),
+		Type:               secHeader.value("TYPE"),
+		PeriodOfReport:     parseNullTime("20060102", secHeader.value("PERIOD")),
+		FilingDate:         parseNullTime("20060102", secHeader.value("FILING-DATE")),
+		AcceptanceDateTime: parseNullTime("20060102150405", secHeader.value("ACCEPTANCE-DATETIME")),
+	}
+	for _, node := range secHeader.children {
+		if node.name == "ITEMS" && len(node.text) > 0 {
+			hdr.Items = append(hdr.Items, node.text)
+		}
+		if containsFold(partyRoles, node.name) {
+			hdr.Parties = append(hdr.Parties, transformParty(node))
+		}
+	}
+	return hdr, nil
+}
+
+// textHeaderToSGML rewrites the indented "KEY: value" lines of the header of
+// a complete submission text file as SGML, so both kinds of headers are read
+// by parseHeader. Keys without a value open a section which ends with the
+// next line indented as deep or less.
+func textHeaderToSGML(lines []string) []byte {
+	type section struct {
+		tag   string
+		depth int
+	}
+	var b strings.Builder
+	var open []section
+	closeTo := func(depth int) {
+		for len(open) > 0 && open[len(open)-1].depth >= depth {
+			b.WriteString("</" + open[len(open)-1].tag + ">\n")
+			open = open[:len(open)-1]
+		}
+	}
+	b.WriteString("<SEC-HEADER>\n")
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) < 1 {
+			continue
+		}
+		depth := len(line) - len(strings.TrimLeft(line, "\t"))
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "<") {
+			closeTo(0)
+			b.WriteString(line + "\n")
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		closeTo(depth)
+		// the text header describes items instead of listing their codes,
+		// they are left to the submissions JSON
+		if key == "ITEM INFORMATION" {
+			continue
+		}
+		tag, ok := textHeaderTags[key]
+		if !ok {
+			tag = strings.ReplaceAll(key, " ", "-")
+		}
+		value = strings.TrimSpace(value)
+		if len(value) < 1 {
+			b.WriteString("<" + tag + ">\n")
+			open = append(open, section{tag: tag, depth: depth})
+			continue
+		}
+		b.WriteString("<" + tag + ">" + textHeaderValue(tag, value) + "\n")
+	}
+	closeTo(0)
+	b.WriteString("</SEC-HEADER>\n")
+	return []byte(b.String())
+}
+
+// textHeaderValue converts the values which the text header spells out,
+// e.g. "ELECTRONIC COMPUTERS [3571]" and "1934 Act", to their SGML form.
+func textHeaderValue(tag string, value string) string {
+	switch tag {
+	case "ASSIGNED-SIC":
+		start, end := strings.LastIndex(value, "["), strings.LastIndex(value, "]")
+		if start >= 0 && end > start {
+			return value[start+1 : end]
+		}
+	case "ACT":
+		value = strings.TrimSuffix(value, " Act")
+		if len(value) == 4 && strings.HasPrefix(value, "19") {
+			return value[2:]
+		}
+	}
+	return value
+}
+
+func transformParty(node *sgmlNode) *HeaderParty {
+	party := &HeaderParty{Role: node.name}
+	data := node.child("COMPANY-DATA")
+	if data == nil {
+		data = node.child("OWNER-DATA")
+	}
+	if data != nil {
+		party.Name = data.value("CONFORMED-NAME")
+		party.CIK = data.value("CIK")
+		party.SIC = data.value("ASSIGNED-SIC")
+		party.IRSNumber = data.value("IRS-NUMBER")
+		party.StateOfIncorporation = data.value("STATE-OF-INCORPORATION")
+		party.FiscalYearEnd = data.value("FISCAL-YEAR-END")
+		party.FilerStatus = data.value("FILER-STATUS")
+	}
+	if values := node.child("FILING-VALUES"); values != nil {
+		party.FormType = values.value("FORM-TYPE")
+		party.Act = values.value("ACT")
+		party.FileNumber = values.value("FILE-NUMBER")
+		party.FilmNumber = values.value("FILM-NUMBER")
+	}
+	party.BusinessAddress = transformAddress(node.child("BUSINESS-ADDRESS"))
+	party.MailAddress = transformAddress(node.child("MAIL-ADDRESS"))
+	return party
+}
+
+func transformAddress(node *sgmlNode) *Address {
+	if node == nil {
+		return nil
+	}
+	return &Address{
+		Street1: node.value("STREET1"),
+		Street2: node.value("STREET2"),
+		City:    node.value("CITY"),
+		State:   node.value("STATE"),
+		Zip:     node.value("ZIP"),
+		Phone:   node.value("PHONE"),
+	}
+}
+
+type sgmlNode struct {
+	name     string
+	text     string
+	children []*sgmlNode
+}
+
+func (n *sgmlNode) child(name string) *sgmlNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *sgmlNode) value(name string) string {
+	if c := n.child(name); c != nil {
+		return c.text
+	}
+	return ""
+}
+
+// parseSGML builds a tree from EDGAR's header SGML. Only tags which have an
+// end tag somewhere in the document open an element, every other tag is a
+// leaf with the rest of its line as value. This keeps flag tags like <PAPER>
+// from swallowing the elements following them.
+func parseSGML(data []byte) (*sgmlNode, error) {
+	var lines []string
+	closed := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "<") {
+			continue
+		}
+		end := strings.Index(line, ">")
+		if end < 0 {
+			return nil, fmt.Errorf("%w, unterminated tag '%s'", ErrMalformed, line)
+		}
+		if strings.HasPrefix(line, "</") {
+			closed[line[2:end]] = true
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	root := &sgmlNode{}
+	stack := []*sgmlNode{root}
+	for _, line := range lines {
+		end := strings.Index(line, ">")
+		name := line[1:end]
+		if strings.HasPrefix(name, "/") {
+			idx := len(stack) - 1
+			for idx > 0 && stack[idx].name != name[1:] {
+				idx--
+			}
+			if idx > 0 {
+				stack = stack[:idx]
+			}
+			continue
+		}
+		node := &sgmlNode{name: name, text: strings.TrimSpace(line[end+1:])}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, node)
+		if closed[name] {
+			stack = append(stack, node)
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,132 @@
+package external
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testHeader = `<SEC-HEADER>0000320193-23-000106.hdr.sgml : 20231103
+<ACCEPTANCE-DATETIME>20231102180827
+<ACCESSION-NUMBER>0000320193-23-000106
+<TYPE>10-K
+<PUBLIC-DOCUMENT-COUNT>96
+<PERIOD>20230930
+<ITEMS>5.02
+<ITEMS>9.01
+<FILING-DATE>20231103
+<PAPER>
+<FILER>
+<COMPANY-DATA>
+<CONFORMED-NAME>Apple Inc.
+<CIK>0000320193
+<ASSIGNED-SIC>3571
+<IRS-NUMBER>942404110
+<STATE-OF-INCORPORATION>CA
+<FISCAL-YEAR-END>0930
+</COMPANY-DATA>
+<FILING-VALUES>
+<FORM-TYPE>10-K
+<ACT>34
+<FILE-NUMBER>001-36743
+<FILM-NUMBER>231373899
+</FILING-VALUES>
+<BUSINESS-ADDRESS>
+<STREET1>ONE APPLE PARK WAY
+<CITY>CUPERTINO
+<STATE>CA
+<ZIP>95014
+<PHONE>(408) 996-1010
+</BUSINESS-ADDRESS>
+<MAIL-ADDRESS>
+<STREET1>ONE APPLE PARK WAY
+<CITY>CUPERTINO
+<STATE>CA
+<ZIP>95014
+</MAIL-ADDRESS>
+<FORMER-COMPANY>
+<FORMER-CONFORMED-NAME>APPLE INC
+<DATE-CHANGED>19970808
+</FORMER-COMPANY>
+</FILER>
+<FILER>
+<COMPANY-DATA>
+<CONFORMED-NAME>Apple Operations International
+<CIK>0001234567
+</COMPANY-DATA>
+</FILER>
+<SUBJECT-COMPANY>
+<COMPANY-DATA>
+<CONFORMED-NAME>Target Corp
+<CIK>0000027419
+</COMPANY-DATA>
+</SUBJECT-COMPANY>
+</SEC-HEADER>
+`
+
+func TestParseHeader(t *testing.T) {
+	hdr, err := parseHeader([]byte(testHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.AccessionNumber != "0000320193-23-000106" || hdr.Type != "10-K" {
+		t.Errorf("got accession %s type %s", hdr.AccessionNumber, hdr.Type)
+	}
+	msg, ok := checkNullTime(hdr.PeriodOfReport, sqlTime(time.Date(2023, time.September, 30, 0, 0, 0, 0, time.UTC)))
+	if !ok {
+		t.Errorf("PeriodOfReport " + msg)
+	}
+	msg, ok = checkNullTime(hdr.AcceptanceDateTime, sqlTime(time.Date(2023, time.November, 2, 18, 8, 27, 0, time.UTC)))
+	if !ok {
+		t.Errorf("AcceptanceDateTime " + msg)
+	}
+	if len(hdr.Items) != 2 || hdr.Items[0] != "5.02" || hdr.Items[1] != "9.01" {
+		t.Errorf("got items %v, want [5.02 9.01]", hdr.Items)
+	}
+	if len(hdr.Parties) != 3 {
+		t.Fatalf("got %d parties, want 3", len(hdr.Parties))
+	}
+	filer := hdr.Parties[0]
+	if filer.Role != "FILER" || filer.Name != "Apple Inc." || filer.CIK != "0000320193" {
+		t.Errorf("got filer %s %s %s", filer.Role, filer.Name, filer.CIK)
+	}
+	if filer.SIC != "3571" || filer.StateOfIncorporation != "CA" || filer.FiscalYearEnd != "0930" {
+		t.Errorf("got SIC %s state %s fiscal year end %s", filer.SIC, filer.StateOfIncorporation, filer.FiscalYearEnd)
+	}
+	if filer.FileNumber != "001-36743" || filer.FilmNumber != "231373899" || filer.Act != "34" {
+		t.Errorf("got file number %s film number %s act %s", filer.FileNumber, filer.FilmNumber, filer.Act)
+	}
+	if filer.BusinessAddress == nil || filer.BusinessAddress.Phone != "(408) 996-1010" {
+		t.Errorf("got business address %v", filer.BusinessAddress)
+	}
+	if filer.MailAddress == nil || filer.MailAddress.City != "CUPERTINO" {
+		t.Errorf("got mail address %v", filer.MailAddress)
+	}
+	if hdr.Parties[1].Role != "FILER" || hdr.Parties[1].CIK != "0001234567" {
+		t.Errorf("got co-registrant %s %s", hdr.Parties[1].Role, hdr.Parties[1].CIK)
+	}
+	if hdr.Parties[1].BusinessAddress != nil {
+		t.Errorf("expected co-registrant without business address")
+	}
+	if hdr.Parties[2].Role != "SUBJECT-COMPANY" || hdr.Parties[2].Name != "Target Corp" {
+		t.Errorf("got subject company %s %s", hdr.Parties[2].Role, hdr.Parties[2].Name)
+	}
+}
+
+func TestParseHeaderErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+	}{
+		{"No SEC-HEADER", "<TYPE>10-K\n"},
+		{"Unterminated tag", "<SEC-HEADER\n</SEC-HEADER>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseHeader([]byte(test.input))
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("got error %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
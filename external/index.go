@@ -0,0 +1,199 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Ranges with at most this many days in a quarter are read from the daily
+// indexes, longer ones from the much larger quarterly full index.
+const dailyIndexMaxDays = 14
+
+// IndexEntry is a single filing listed in an EDGAR form.idx or master.idx.
+type IndexEntry struct {
+	CIK             string
+	CompanyName     string
+	Form            string
+	DateFiled       sql.NullTime
+	FileName        string
+	AccessionNumber string
+}
+
+func (e *IndexEntry) GetID() string {
+	return strings.Replace(e.AccessionNumber, "-", "", -1)
+}
+
+// GetIndex lists the filings between from and to, both inclusive, whose form
+// is allowed by the policy of the filer.
+func (api *API) GetIndex(ctx context.Context, from time.Time, to time.Time, policies *PolicyConfig) ([]*IndexEntry, error) {
+	from = truncateDay(from)
+	to = truncateDay(to)
+	var entries []*IndexEntry
+	for q := quarterStart(from); !q.After(to); q = q.AddDate(0, 3, 0) {
+		start, end := q, q.AddDate(0, 3, -1)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		var found []*IndexEntry
+		if int(end.Sub(start).Hours()/24)+1 > dailyIndexMaxDays {
+			var err error
+			found, err = api.getIndexFile(ctx, fmt.Sprintf("full-index/%d/QTR%d/master.idx", q.Year(), quarterOf(q)))
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+				if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+					continue
+				}
+				name := fmt.Sprintf("daily-index/%d/QTR%d/master.%s.idx", day.Year(), quarterOf(day), day.Format("20060102"))
+				dayEntries, err := api.getIndexFile(ctx, name)
+				// holidays and today before the nightly build have no index
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				found = append(found, dayEntries...)
+			}
+		}
+		for _, e := range found {
+			if e.DateFiled.Valid && (e.DateFiled.Time.Before(start) || e.DateFiled.Time.After(end)) {
+				continue
+			}
+			if len(policies.For(e.CIK).checkForm(e.Form)) > 0 {
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (api *API) getIndexFile(ctx context.Context, name string) ([]*IndexEntry, error) {
+	data, err := api.fetch(ctx, api.indexURL+name)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get index %s, %w", name, err)
+	}
+	entries, err := parseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse index %s, %w", name, err)
+	}
+	return entries, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func quarterOf(t time.Time) int {
+	return (int(t.Month())-1)/3 + 1
+}
+
+func quarterStart(t time.Time) time.Time {
+	return time.Date(t.Year(), time.Month((quarterOf(t)-1)*3+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// parseIndex reads the pipe delimited master.idx and the fixed width form.idx
+// of both the daily and the full index. The column header is the line above
+// the dashed separator.
+func parseIndex(data []byte) ([]*IndexEntry, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var header string
+	var parse func(line string) (*IndexEntry, error)
+	var entries []*IndexEntry
+	prev := ""
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if parse == nil {
+			if strings.HasPrefix(line, "---") {
+				header = prev
+				switch {
+				case strings.Contains(header, "|"):
+					parse = parseMasterLine
+				case strings.HasPrefix(header, "Form Type") && strings.Contains(header, "Company Name"):
+					companyStart := strings.Index(header, "Company Name")
+					parse = func(line string) (*IndexEntry, error) {
+						return parseFormLine(line, companyStart)
+					}
+				default:
+					return nil, fmt.Errorf("%w, unknown index header '%s'", ErrMalformed, header)
+				}
+			}
+			prev = line
+			continue
+		}
+		if len(strings.TrimSpace(line)) < 1 {
+			continue
+		}
+		entry, err := parse(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if parse == nil {
+		return nil, fmt.Errorf("%w, index has no column header", ErrMalformed)
+	}
+	return entries, nil
+}
+
+func parseMasterLine(line string) (*IndexEntry, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w, index line '%s' has %d fields", ErrMalformed, line, len(fields))
+	}
+	return newIndexEntry(fields[0], fields[1], fields[2], fields[3], fields[4], line)
+}
+
+// parseFormLine takes the form type from its fixed width column, while CIK,
+// date and file name are read from the end of the line since long company
+// names overflow their column.
+func parseFormLine(line string, companyStart int) (*IndexEntry, error) {
+	if len(line) <= companyStart {
+		return nil, fmt.Errorf("%w, index line '%s' is too short", ErrMalformed, line)
+	}
+	form := line[:companyStart]
+	fields := strings.Fields(line[companyStart:])
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("%w, index line '%s' has too few fields", ErrMalformed, line)
+	}
+	n := len(fields)
+	company := strings.Join(fields[:n-3], " ")
+	return newIndexEntry(fields[n-3], company, form, fields[n-2], fields[n-1], line)
+}
+
+func newIndexEntry(cik, company, form, date, fileName, line string) (*IndexEntry, error) {
+	normalized, err := NormalizeCIK(cik)
+	if err != nil {
+		return nil, fmt.Errorf("%w, index line '%s', %s", ErrMalformed, line, err.Error())
+	}
+	fileName = strings.TrimSpace(fileName)
+	dateFiled := parseNullTime("2006-01-02", strings.TrimSpace(date))
+	if !dateFiled.Valid {
+		dateFiled = parseNullTime("20060102", strings.TrimSpace(date))
+	}
+	return &IndexEntry{
+		CIK:             normalized,
+		CompanyName:     strings.TrimSpace(company),
+		Form:            strings.TrimSpace(form),
+		DateFiled:       dateFiled,
+		FileName:        fileName,
+		AccessionNumber: strings.TrimSuffix(path.Base(fileName), ".txt"),
+	}, nil
+}
@@ -0,0 +1,144 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testMasterIndex = []byte(`Description:           Daily Index of EDGAR Dissemination Feed by Company Name
+Last Data Received:    November 3, 2023
+Comments:              webmaster@sec.gov
+Anonymous FTP:         ftp://ftp.sec.gov/edgar/
+
+CIK|Company Name|Form Type|Date Filed|File Name
+--------------------------------------------------------------------------------
+1000045|NICHOLAS FINANCIAL INC|8-K|20231103|edgar/data/1000045/0000950170-23-060374.txt
+320193|Apple Inc.|10-K|20231103|edgar/data/320193/0000320193-23-000106.txt
+`)
+
+var testFormIndex = []byte(`Description:           Master Index of EDGAR Dissemination Feed by Form Type
+Last Data Received:    December 31, 2023
+Comments:              webmaster@sec.gov
+Anonymous FTP:         ftp://ftp.sec.gov/edgar/
+Cloud HTTP:            https://www.sec.gov/Archives/
+
+Form Type   Company Name                                                  CIK         Date Filed  File Name
+---------------------------------------------------------------------------------------------------------------------------------------------
+10-K        Apple Inc.                                                    320193      2023-11-03  edgar/data/320193/0000320193-23-000106.txt
+SC 13G/A    VANGUARD GROUP INC                                            102909      2023-10-10  edgar/data/102909/0000932471-23-011111.txt
+10-Q        A VERY LONG COMPANY NAME WHICH OVERFLOWS ITS COLUMN IN THE INDEX FILE 1234567     2023-11-09  edgar/data/1234567/0001234567-23-000001.txt
+`)
+
+func TestParseIndex(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input []byte
+		err   error
+		want  []IndexEntry
+	}{
+		{
+			"Master index",
+			testMasterIndex,
+			nil,
+			[]IndexEntry{
+				{
+					CIK:             "0001000045",
+					CompanyName:     "NICHOLAS FINANCIAL INC",
+					Form:            "8-K",
+					FileName:        "edgar/data/1000045/0000950170-23-060374.txt",
+					AccessionNumber: "0000950170-23-060374",
+				},
+				{
+					CIK:             "0000320193",
+					CompanyName:     "Apple Inc.",
+					Form:            "10-K",
+					FileName:        "edgar/data/320193/0000320193-23-000106.txt",
+					AccessionNumber: "0000320193-23-000106",
+				},
+			},
+		},
+		{
+			"Form index",
+			testFormIndex,
+			nil,
+			[]IndexEntry{
+				{
+					CIK:             "0000320193",
+					CompanyName:     "Apple Inc.",
+					Form:            "10-K",
+					FileName:        "edgar/data/320193/0000320193-23-000106.txt",
+					AccessionNumber: "0000320193-23-000106",
+				},
+				{
+					CIK:             "0000102909",
+					CompanyName:     "VANGUARD GROUP INC",
+					Form:            "SC 13G/A",
+					FileName:        "edgar/data/102909/0000932471-23-011111.txt",
+					AccessionNumber: "0000932471-23-011111",
+				},
+				{
+					CIK:             "0001234567",
+					CompanyName:     "A VERY LONG COMPANY NAME WHICH OVERFLOWS ITS COLUMN IN THE INDEX FILE",
+					Form:            "10-Q",
+					FileName:        "edgar/data/1234567/0001234567-23-000001.txt",
+					AccessionNumber: "0001234567-23-000001",
+				},
+			},
+		},
+		{"No column header", []byte("Description: nothing\n"), errors.New(""), nil},
+		{
+			"Missing fields",
+			[]byte("CIK|Company Name|Form Type|Date Filed|File Name\n---\n320193|Apple Inc.|10-K\n"),
+			errors.New(""),
+			nil,
+		},
+		{
+			"Invalid CIK",
+			[]byte("CIK|Company Name|Form Type|Date Filed|File Name\n---\nAAPL|Apple Inc.|10-K|20231103|a.txt\n"),
+			errors.New(""),
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseIndex(test.input)
+			if (err != nil) != (test.err != nil) {
+				t.Errorf("got error %v, want error %v", err, test.err)
+				return
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("got %d entries, want %d", len(got), len(test.want))
+				return
+			}
+			for i, v := range got {
+				if !v.DateFiled.Valid {
+					t.Errorf("entry %s has no filing date", v.AccessionNumber)
+				}
+				v.DateFiled.Valid, v.DateFiled.Time = false, time.Time{}
+				if *v != test.want[i] {
+					t.Errorf("got %+v, want %+v", *v, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetIndex(t *testing.T) {
+	// Friday to Monday reads two daily indexes, Monday is not published yet
+	api := newTestAPI([][]byte{testMasterIndex, nil})
+	api.client.(*testClient).errs = []error{nil, &ResponseError{Status: 404, Err: ErrNotFound}}
+	from := time.Date(2023, time.November, 3, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.November, 6, 0, 0, 0, 0, time.UTC)
+	got, err := api.GetIndex(context.Background(), from, to, DefaultPolicyConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].AccessionNumber != "0000320193-23-000106" {
+		t.Errorf("got %d entries, want only the 10-K of Apple", len(got))
+	}
+	if api.client.(*testClient).index != 2 {
+		t.Errorf("got %d requests, want 2", api.client.(*testClient).index)
+	}
+}
@@ -0,0 +1,134 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxRate is the number of requests per second the SEC allows.
+const MaxRate = 10
+
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    int
+	hosts    map[string]*bucket
+	requests int64
+	waits    int64
+	waited   time.Duration
+	now      func() time.Time
+	sleep    func(context.Context, time.Duration) error
+}
+
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+type LimiterStats struct {
+	Requests int64
+	Waits    int64
+	Waited   time.Duration
+}
+
+func NewRateLimiter(rate float64, burst int) (*RateLimiter, error) {
+	if burst < 1 {
+		burst = 1
+	}
+	if err := checkRate(rate, burst); err != nil {
+		return nil, err
+	}
+	return &RateLimiter{
+		rate:  rate,
+		burst: burst,
+		hosts: make(map[string]*bucket),
+		now:   time.Now,
+		sleep: sleepContext,
+	}, nil
+}
+
+// checkRate rejects rates which would disable limiting or exceed what the
+// SEC allows, and bursts which would send more than a second's worth of
+// requests at once.
+func checkRate(rate float64, burst int) error {
+	if rate <= 0 || rate > MaxRate {
+		return errors.New(fmt.Sprintf("Rate limit must be greater than 0 and at most %d requests per second, got %g", MaxRate, rate))
+	}
+	if burst > 1 && float64(burst) > rate {
+		return errors.New(fmt.Sprintf("Burst must be at most the rate limit of %g requests per second, got %d", rate, burst))
+	}
+	return nil
+}
+
+// SetHostLimit gives a host its own rate and burst instead of the defaults
+// every other host's bucket is created with.
+func (l *RateLimiter) SetHostLimit(host string, rate float64, burst int) error {
+	if burst < 1 {
+		burst = 1
+	}
+	if err := checkRate(rate, burst); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hosts[host] = &bucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+	return nil
+}
+
+// Wait blocks until the bucket of the host has a token available or ctx is
+// done. Tokens are reserved before sleeping, so concurrent callers queue up
+// behind each other.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	b, ok := l.hosts[host]
+	if !ok {
+		b = &bucket{rate: l.rate, burst: float64(l.burst), tokens: float64(l.burst)}
+		l.hosts[host] = b
+	}
+	now := l.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 && b.rate > 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	l.requests++
+	if wait > 0 {
+		l.waits++
+		l.waited += wait
+	}
+	l.mu.Unlock()
+	if wait > 0 {
+		return l.sleep(ctx, wait)
+	}
+	return nil
+}
+
+// sleepContext sleeps for d unless ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *RateLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{Requests: l.requests, Waits: l.waits, Waited: l.waited}
+}
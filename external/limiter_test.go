@@ -0,0 +1,146 @@
+package external
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	var tests = []struct {
+		name   string
+		rate   float64
+		burst  int
+		hosts  []string
+		gap    time.Duration
+		waits  int64
+		waited time.Duration
+	}{
+		{"Single request", 5, 1, []string{"data.sec.gov"}, 0, 0, 0},
+		{
+			"Back to back requests",
+			5,
+			1,
+			[]string{"data.sec.gov", "data.sec.gov", "data.sec.gov"},
+			0,
+			2,
+			400 * time.Millisecond,
+		},
+		{
+			"Burst absorbs back to back requests",
+			5,
+			3,
+			[]string{"data.sec.gov", "data.sec.gov", "data.sec.gov"},
+			0,
+			0,
+			0,
+		},
+		{
+			"Separate buckets per host",
+			5,
+			1,
+			[]string{"data.sec.gov", "www.sec.gov"},
+			0,
+			0,
+			0,
+		},
+		{
+			"Requests spaced by the rate",
+			5,
+			1,
+			[]string{"www.sec.gov", "www.sec.gov", "www.sec.gov"},
+			200 * time.Millisecond,
+			0,
+			0,
+		},
+		{
+			"Requests spaced below the rate",
+			5,
+			1,
+			[]string{"www.sec.gov", "www.sec.gov"},
+			100 * time.Millisecond,
+			1,
+			100 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			limiter, err := NewRateLimiter(test.rate, test.burst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			limiter.now = func() time.Time { return now }
+			limiter.sleep = func(ctx context.Context, d time.Duration) error {
+				now = now.Add(d)
+				return nil
+			}
+			for _, host := range test.hosts {
+				limiter.Wait(context.Background(), host)
+				now = now.Add(test.gap)
+			}
+			stats := limiter.Stats()
+			if stats.Requests != int64(len(test.hosts)) {
+				t.Errorf("got %d requests, want %d", stats.Requests, len(test.hosts))
+			}
+			if stats.Waits != test.waits {
+				t.Errorf("got %d waits, want %d", stats.Waits, test.waits)
+			}
+			if (stats.Waited - test.waited).Abs() > time.Millisecond {
+				t.Errorf("got waited %s, want %s", stats.Waited, test.waited)
+			}
+		})
+	}
+}
+
+func TestRateLimiterHostLimit(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	limiter, err := NewRateLimiter(5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.SetHostLimit("data.sec.gov", 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		now = now.Add(d)
+		return nil
+	}
+	limiter.Wait(context.Background(), "data.sec.gov")
+	limiter.Wait(context.Background(), "data.sec.gov")
+	if waited := limiter.Stats().Waited; (waited - 100*time.Millisecond).Abs() > time.Millisecond {
+		t.Errorf("got waited %s, want %s", waited, 100*time.Millisecond)
+	}
+}
+
+func TestRateLimiterRates(t *testing.T) {
+	var tests = []struct {
+		name  string
+		rate  float64
+		burst int
+		valid bool
+	}{
+		{"Default rate", 5, 1, true},
+		{"SEC maximum", 10, 1, true},
+		{"Above SEC maximum", 11, 1, false},
+		{"Zero", 0, 1, false},
+		{"Negative", -1, 1, false},
+		{"Burst of the rate", 5, 5, true},
+		{"Single request below one per second", 0.5, 1, true},
+		{"Burst above the rate", 5, 1000, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewRateLimiter(test.rate, test.burst)
+			if test.valid != (err == nil) {
+				t.Errorf("got error %v for rate %g and burst %d", err, test.rate, test.burst)
+			}
+			limiter, _ := NewRateLimiter(5, 1)
+			err = limiter.SetHostLimit("www.sec.gov", test.rate, test.burst)
+			if test.valid != (err == nil) {
+				t.Errorf("got error %v for host rate %g and burst %d", err, test.rate, test.burst)
+			}
+		})
+	}
+}
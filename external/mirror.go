@@ -0,0 +1,112 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// mirrorClient answers file:// URLs from a local copy of EDGAR with the same
+// directory layout, every other URL goes to the next client. Filing folders
+// without an index.json get one listing their files.
+type mirrorClient struct {
+	next client
+}
+
+func (c *mirrorClient) buildRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	return c.next.buildRequest(ctx, urlStr)
+}
+
+func (c *mirrorClient) sendRequest(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "file" {
+		return c.next.sendRequest(req)
+	}
+	name := filepath.FromSlash(req.URL.Path)
+	res := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) && path.Base(req.URL.Path) == "index.json" {
+		return c.listing(res, filepath.Dir(name))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return mirrorStatus(res, http.StatusNotFound), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return mirrorStatus(res, http.StatusNotFound), nil
+	}
+	res.Status, res.StatusCode = "200 OK", http.StatusOK
+	res.Body = f
+	res.ContentLength = info.Size()
+	res.Header.Set("Content-Type", mime.TypeByExtension(filepath.Ext(name)))
+	res.Header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	return res, nil
+}
+
+func (c *mirrorClient) listing(res *http.Response, dir string) (*http.Response, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return mirrorStatus(res, http.StatusNotFound), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	filRes := &filesResponse{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		it := item{Name: e.Name(), Type: "text.gif", Size: strconv.FormatInt(info.Size(), 10)}
+		if e.IsDir() {
+			it.Type, it.Size = "folder.gif", ""
+		}
+		it.LastModified = info.ModTime().UTC().Format("2006-01-02 15:04:05")
+		filRes.Dir.Items = append(filRes.Dir.Items, it)
+	}
+	data, err := json.Marshal(filRes)
+	if err != nil {
+		return nil, err
+	}
+	res.Status, res.StatusCode = "200 OK", http.StatusOK
+	res.Body = io.NopCloser(strings.NewReader(string(data)))
+	res.ContentLength = int64(len(data))
+	res.Header.Set("Content-Type", "application/json")
+	return res, nil
+}
+
+func mirrorStatus(res *http.Response, status int) *http.Response {
+	res.Status, res.StatusCode = strconv.Itoa(status)+" "+http.StatusText(status), status
+	res.Body = http.NoBody
+	return res
+}
+
+func (c *mirrorClient) getData(res *http.Response) ([]byte, error) {
+	return c.next.getData(res)
+}
+
+func (c *mirrorClient) getStream(res *http.Response) (io.ReadCloser, error) {
+	return c.next.getStream(res)
+}
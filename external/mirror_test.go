@@ -0,0 +1,72 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	root := t.TempDir()
+	filingDir := filepath.Join(root, "Archives", "edgar", "data", "0000320193", "000032019323000106")
+	os.MkdirAll(filingDir, 0755)
+	os.MkdirAll(filepath.Join(root, "submissions"), 0755)
+	os.WriteFile(filepath.Join(root, "submissions", "CIK0000320193.json"), []byte(`{
+		"cik":"320193",
+		"name":"Apple Inc.",
+		"filings":{"recent":{
+			"accessionNumber":["0000320193-23-000106"],
+			"filingDate":["2023-11-03"],
+			"reportDate":["2023-09-30"],
+			"acceptanceDateTime":["2023-11-02T18:08:27.000Z"],
+			"form":["10-K"],
+			"primaryDocument":["aapl-20230930.htm"]
+		}}
+	}`), 0644)
+	os.WriteFile(filepath.Join(filingDir, "aapl-20230930.htm"), testDocument, 0644)
+	limiter, err := NewRateLimiter(0.001, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := NewAPI(&Config{
+		Name:           "Example Corp",
+		Email:          "data@example.com",
+		ArchivesURL:    "file://" + filepath.ToSlash(filepath.Join(root, "Archives", "edgar")),
+		SubmissionsURL: "file://" + filepath.ToSlash(filepath.Join(root, "submissions")),
+	}, limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := api.GetSubmissions(context.Background(), "0000320193", DefaultFormPolicy(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.Filings) != 1 {
+		t.Fatalf("got %d filings, want 1", len(sub.Filings))
+	}
+	mainFile, err := api.GetMainFile(context.Background(), "0000320193", sub.Filings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mainFile.Size != int64(len(testDocument)) || !mainFile.LastModified.Valid {
+		t.Errorf("got main file %+v from directory listing", *mainFile)
+	}
+	body, err := api.OpenFile(context.Background(), "0000320193", sub.Filings[0], mainFile.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != string(testDocument) {
+		t.Errorf("got %d bytes, want %d", len(data), len(testDocument))
+	}
+	if _, err := api.GetSubmissions(context.Background(), "0000789019", DefaultFormPolicy(), false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+	if stats := api.LimiterStats(); stats.Requests != 0 {
+		t.Errorf("got %d requests through the rate limiter, want none for a mirror", stats.Requests)
+	}
+}
@@ -0,0 +1,176 @@
+package external
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var formFamilies = map[string][]string{
+	"annual":       {"10-K", "10-K405", "10-KT", "20-F", "40-F"},
+	"quarterly":    {"10-Q", "10-QT"},
+	"current":      {"8-K", "6-K"},
+	"registration": {"S-1", "S-3", "S-4", "F-1", "F-3", "F-4"},
+	"proxy":        {"DEF 14A", "DEFA14A", "PRE 14A"},
+}
+
+type FormPolicy struct {
+	Include    []string `json:"include"`
+	Exclude    []string `json:"exclude"`
+	Families   []string `json:"families"`
+	Amendments bool     `json:"amendments"`
+	Extensions []string `json:"extensions"`
+	// MaxSize skips filings whose submission is larger, policies which
+	// leave it at 0 take MAX_FILING_SIZE.
+	MaxSize int64 `json:"maxSize"`
+}
+
+func DefaultFormPolicy() *FormPolicy {
+	return &FormPolicy{
+		Include:    []string{"10-K", "10-Q"},
+		Extensions: []string{".htm"},
+	}
+}
+
+func (p *FormPolicy) validate() error {
+	for _, family := range p.Families {
+		if _, ok := formFamilies[family]; !ok {
+			return errors.New(fmt.Sprintf("Unknown form family '%s'", family))
+		}
+	}
+	if len(p.Include) < 1 && len(p.Families) < 1 {
+		return errors.New("Form policy must include at least one form or family")
+	}
+	return nil
+}
+
+// check returns the reason why a filing is rejected, or an empty string when
+// the policy allows it.
+func (p *FormPolicy) check(form string, primDoc string) string {
+	if reason := p.checkForm(form); len(reason) > 0 {
+		return reason
+	}
+	ext, err := (&file{Name: primDoc}).GetExtension()
+	if err != nil {
+		return "primary document has no extension"
+	}
+	if len(p.Extensions) > 0 && !containsFold(p.Extensions, ext) {
+		return fmt.Sprintf("primary document extension '%s' not allowed", ext)
+	}
+	return ""
+}
+
+// checkSize returns the reason why a filing is rejected for the size of its
+// submission, or an empty string when the policy allows it.
+func (p *FormPolicy) checkSize(size int64) string {
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return fmt.Sprintf("size of %d bytes exceeds maximum of %d", size, p.MaxSize)
+	}
+	return ""
+}
+
+// AllowsForm reports whether the form rules of the policy accept a form.
+func (p *FormPolicy) AllowsForm(form string) bool {
+	return len(p.checkForm(form)) < 1
+}
+
+// checkForm applies only the form rules, for sources like the EDGAR indexes
+// which do not name the primary document.
+func (p *FormPolicy) checkForm(form string) string {
+	base, amended := strings.CutSuffix(form, "/A")
+	if amended && !p.Amendments {
+		return "amendments not allowed"
+	}
+	if containsFold(p.Exclude, form) || containsFold(p.Exclude, base) {
+		return "form excluded"
+	}
+	if !p.includes(form, base) {
+		return "form not included"
+	}
+	return ""
+}
+
+func (p *FormPolicy) includes(form string, base string) bool {
+	if containsFold(p.Include, "*") || containsFold(p.Include, form) || containsFold(p.Include, base) {
+		return true
+	}
+	for _, family := range p.Families {
+		if containsFold(formFamilies[family], base) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type PolicyConfig struct {
+	Default   *FormPolicy            `json:"default"`
+	Companies map[string]*FormPolicy `json:"companies"`
+}
+
+func DefaultPolicyConfig() *PolicyConfig {
+	return &PolicyConfig{Default: DefaultFormPolicy()}
+}
+
+func LoadPolicyConfig(path string) (*PolicyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &PolicyConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, errors.New("Could not process JSON into struct PolicyConfig, " + err.Error())
+	}
+	if cfg.Default == nil {
+		cfg.Default = DefaultFormPolicy()
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, err
+	}
+	companies := make(map[string]*FormPolicy, len(cfg.Companies))
+	for cik, policy := range cfg.Companies {
+		if err := policy.validate(); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid form policy for CIK '%s', %s", cik, err.Error()))
+		}
+		companies[strings.TrimLeft(cik, "0")] = policy
+	}
+	cfg.Companies = companies
+	return cfg, nil
+}
+
+// LimitSize sets the maximum submission size of every policy which does not
+// set its own.
+func (c *PolicyConfig) LimitSize(max int64) {
+	policies := []*FormPolicy{c.Default}
+	for _, policy := range c.Companies {
+		policies = append(policies, policy)
+	}
+	for _, policy := range policies {
+		if policy.MaxSize == 0 {
+			policy.MaxSize = max
+		}
+	}
+}
+
+func (c *PolicyConfig) For(cik string) *FormPolicy {
+	if policy, ok := c.Companies[strings.TrimLeft(cik, "0")]; ok {
+		return policy
+	}
+	return c.Default
+}
+
+type Skipped struct {
+	SecID  string
+	Form   string
+	Reason string
+}
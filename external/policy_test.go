@@ -0,0 +1,175 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormPolicyCheck(t *testing.T) {
+	var tests = []struct {
+		name    string
+		policy  *FormPolicy
+		form    string
+		primDoc string
+		allowed bool
+	}{
+		{"Default allows 10-K", DefaultFormPolicy(), "10-K", "test.htm", true},
+		{"Default allows 10-Q", DefaultFormPolicy(), "10-Q", "test.htm", true},
+		{"Default rejects 8-K", DefaultFormPolicy(), "8-K", "test.htm", false},
+		{"Default rejects amendment", DefaultFormPolicy(), "10-K/A", "test.htm", false},
+		{"Default rejects text document", DefaultFormPolicy(), "10-K", "test.txt", false},
+		{"Default rejects document without extension", DefaultFormPolicy(), "10-K", "test", false},
+		{
+			"Family includes 20-F",
+			&FormPolicy{Families: []string{"annual"}},
+			"20-F",
+			"test.htm",
+			true,
+		},
+		{
+			"Family does not include 10-Q",
+			&FormPolicy{Families: []string{"annual"}},
+			"10-Q",
+			"test.htm",
+			false,
+		},
+		{
+			"Amendment of included form",
+			&FormPolicy{Include: []string{"10-K"}, Amendments: true},
+			"10-K/A",
+			"test.htm",
+			true,
+		},
+		{
+			"Amendment of family form",
+			&FormPolicy{Families: []string{"annual"}, Amendments: true},
+			"10-K405/A",
+			"test.htm",
+			true,
+		},
+		{
+			"Exclude wins over family",
+			&FormPolicy{Families: []string{"annual"}, Exclude: []string{"40-F"}},
+			"40-F",
+			"test.htm",
+			false,
+		},
+		{
+			"Exclude of base form excludes amendment",
+			&FormPolicy{Include: []string{"*"}, Exclude: []string{"8-K"}, Amendments: true},
+			"8-K/A",
+			"test.htm",
+			false,
+		},
+		{"Wildcard includes everything", &FormPolicy{Include: []string{"*"}}, "DEF 14A", "a.pdf", true},
+		{
+			"Extensions are case insensitive",
+			&FormPolicy{Include: []string{"6-K"}, Extensions: []string{".htm", ".txt"}},
+			"6-K",
+			"TEST.TXT",
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := test.policy.check(test.form, test.primDoc)
+			if test.allowed && reason != "" {
+				t.Errorf("expected filing to be allowed, but got: %s", reason)
+			}
+			if !test.allowed && reason == "" {
+				t.Errorf("expected filing to be rejected")
+			}
+		})
+	}
+}
+
+func TestLoadPolicyConfig(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"Empty config falls back to default", `{}`, true},
+		{
+			"Default and company override",
+			`{"default":{"families":["annual"]},"companies":{"0000320193":{"include":["8-K"]}}}`,
+			true,
+		},
+		{"Unknown family", `{"default":{"families":["monthly"]}}`, false},
+		{"Policy without forms", `{"companies":{"320193":{"amendments":true}}}`, false},
+		{"Malformed JSON", `{"default":`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(test.content), 0666); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadPolicyConfig(path)
+			if test.valid && err != nil {
+				t.Errorf(err.Error())
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected an error thrown")
+			}
+		})
+	}
+}
+
+func TestPolicyConfigFor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	content := `{"companies":{"320193":{"include":["8-K"]}}}`
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadPolicyConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason := cfg.For("0000320193").check("8-K", "test.htm"); reason != "" {
+		t.Errorf("expected company override to allow 8-K, but got: %s", reason)
+	}
+	if reason := cfg.For("0000789019").check("8-K", "test.htm"); reason == "" {
+		t.Errorf("expected default policy to reject 8-K")
+	}
+}
+
+func TestPolicyConfigLimitSize(t *testing.T) {
+	cfg := &PolicyConfig{
+		Default: DefaultFormPolicy(),
+		Companies: map[string]*FormPolicy{
+			"320193": {Include: []string{"10-K"}, MaxSize: 500},
+		},
+	}
+	cfg.LimitSize(100)
+	data := &recent{
+		AccessNumber: []string{"0000320193-23-000106", "0000320193-23-000077"},
+		AcceptDate:   []string{"2023-11-02T18:08:27.000Z", "2023-08-03T18:04:43.000Z"},
+		FilingDate:   []string{"2023-11-03", "2023-08-04"},
+		ReportDate:   []string{"2023-09-30", "2023-07-01"},
+		Form:         []string{"10-K", "10-Q"},
+		PrimDoc:      []string{"a.htm", "b.htm"},
+		Size:         []int64{200, 50},
+	}
+	var tests = []struct {
+		name    string
+		cik     string
+		skipped int
+	}{
+		{"Default policy takes the limit", "789019", 1},
+		{"Company policy keeps its own limit", "320193", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filings, skipped, _ := transformFilings(data, cfg.For(test.cik))
+			if len(skipped) != test.skipped || len(filings) != 2-test.skipped {
+				t.Errorf("got %d filings and %d skipped, want %d skipped", len(filings), len(skipped), test.skipped)
+			}
+		})
+	}
+	if reason := cfg.Default.checkSize(200); !strings.Contains(reason, "exceeds maximum of 100") {
+		t.Errorf("got reason '%s'", reason)
+	}
+}
@@ -0,0 +1,151 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var accessionPattern = regexp.MustCompile(`^\d{10}-\d{2}-\d{6}$`)
+
+// Rejected is a row of the submissions JSON which failed validation. Row holds
+// the raw values of the row so it can be inspected later.
+type Rejected struct {
+	SecID  string
+	Form   string
+	Reason string
+	Row    string
+}
+
+// checkCIK makes sure EDGAR answered with the submissions of the company
+// which were asked for.
+func checkCIK(want string, got string) error {
+	wantCIK, err := NormalizeCIK(want)
+	if err != nil {
+		return fmt.Errorf("%w, requested submissions of invalid CIK '%s'", ErrMalformed, want)
+	}
+	gotCIK, err := NormalizeCIK(got)
+	if err != nil || gotCIK != wantCIK {
+		return fmt.Errorf("%w, got submissions of CIK '%s' for CIK '%s'", ErrMalformed, got, want)
+	}
+	return nil
+}
+
+// validateRecent splits a page of the submissions JSON into its valid rows
+// and the rejected ones. The arrays of a page are parallel, rows beyond the
+// end of any required array cannot be trusted and are rejected as well.
+func validateRecent(r *recent) (*recent, []*Rejected) {
+	required := []struct {
+		name   string
+		values []string
+	}{
+		{"accessionNumber", r.AccessNumber},
+		{"filingDate", r.FilingDate},
+		{"acceptanceDateTime", r.AcceptDate},
+		{"reportDate", r.ReportDate},
+		{"form", r.Form},
+		{"primaryDocument", r.PrimDoc},
+	}
+	rows, complete := 0, len(r.Form)
+	for _, arr := range required {
+		rows = max(rows, len(arr.values))
+		complete = min(complete, len(arr.values))
+	}
+	valid := &recent{}
+	var rejected []*Rejected
+	for i := 0; i < rows; i++ {
+		reason := ""
+		if i >= complete {
+			var missing []string
+			for _, arr := range required {
+				if i >= len(arr.values) {
+					missing = append(missing, arr.name)
+				}
+			}
+			reason = "missing " + strings.Join(missing, ", ")
+		} else {
+			reason = checkRow(r, i)
+		}
+		if reason != "" {
+			rejected = append(rejected, &Rejected{
+				SecID:  valueAt(r.AccessNumber, i),
+				Form:   valueAt(r.Form, i),
+				Reason: reason,
+				Row:    rowAt(r, i),
+			})
+			continue
+		}
+		valid.appendRow(r, i)
+	}
+	return valid, rejected
+}
+
+func checkRow(r *recent, i int) string {
+	if !accessionPattern.MatchString(r.AccessNumber[i]) {
+		return fmt.Sprintf("invalid accession number '%s'", r.AccessNumber[i])
+	}
+	if len(strings.TrimSpace(r.Form[i])) < 1 {
+		return "missing form"
+	}
+	if _, err := time.Parse("2006-01-02", r.FilingDate[i]); err != nil {
+		return fmt.Sprintf("invalid filing date '%s'", r.FilingDate[i])
+	}
+	if _, err := time.Parse("2006-01-02", r.ReportDate[i]); err != nil && r.ReportDate[i] != "" {
+		return fmt.Sprintf("invalid report date '%s'", r.ReportDate[i])
+	}
+	if _, err := time.Parse(time.RFC3339, r.AcceptDate[i]); err != nil && r.AcceptDate[i] != "" {
+		return fmt.Sprintf("invalid acceptance date '%s'", r.AcceptDate[i])
+	}
+	return ""
+}
+
+// appendRow copies a row, padding optional arrays which are shorter than the
+// required ones so the result stays parallel.
+func (r *recent) appendRow(from *recent, i int) {
+	r.AccessNumber = append(r.AccessNumber, from.AccessNumber[i])
+	r.FilingDate = append(r.FilingDate, from.FilingDate[i])
+	r.AcceptDate = append(r.AcceptDate, from.AcceptDate[i])
+	r.ReportDate = append(r.ReportDate, from.ReportDate[i])
+	r.Form = append(r.Form, from.Form[i])
+	r.PrimDoc = append(r.PrimDoc, from.PrimDoc[i])
+	r.PrimDocDesc = append(r.PrimDocDesc, valueAt(from.PrimDocDesc, i))
+	r.Act = append(r.Act, valueAt(from.Act, i))
+	r.FileNumber = append(r.FileNumber, valueAt(from.FileNumber, i))
+	r.FilmNumber = append(r.FilmNumber, valueAt(from.FilmNumber, i))
+	r.Items = append(r.Items, valueAt(from.Items, i))
+	r.Size = append(r.Size, valueAt(from.Size, i))
+	r.IsXBRL = append(r.IsXBRL, valueAt(from.IsXBRL, i))
+	r.IsInlineXBRL = append(r.IsInlineXBRL, valueAt(from.IsInlineXBRL, i))
+}
+
+// rowAt returns the values of a row as JSON, leaving out the arrays which end
+// before it.
+func rowAt(r *recent, i int) string {
+	row := make(map[string]any)
+	add := func(name string, n int, value any) {
+		if i < n {
+			row[name] = value
+		}
+	}
+	add("accessionNumber", len(r.AccessNumber), valueAt(r.AccessNumber, i))
+	add("filingDate", len(r.FilingDate), valueAt(r.FilingDate, i))
+	add("acceptanceDateTime", len(r.AcceptDate), valueAt(r.AcceptDate, i))
+	add("reportDate", len(r.ReportDate), valueAt(r.ReportDate, i))
+	add("form", len(r.Form), valueAt(r.Form, i))
+	add("primaryDocument", len(r.PrimDoc), valueAt(r.PrimDoc, i))
+	add("primaryDocDescription", len(r.PrimDocDesc), valueAt(r.PrimDocDesc, i))
+	add("act", len(r.Act), valueAt(r.Act, i))
+	add("fileNumber", len(r.FileNumber), valueAt(r.FileNumber, i))
+	add("filmNumber", len(r.FilmNumber), valueAt(r.FilmNumber, i))
+	add("items", len(r.Items), valueAt(r.Items, i))
+	add("size", len(r.Size), valueAt(r.Size, i))
+	add("isXBRL", len(r.IsXBRL), valueAt(r.IsXBRL, i))
+	add("isInlineXBRL", len(r.IsInlineXBRL), valueAt(r.IsInlineXBRL, i))
+	data, err := json.Marshal(row)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,174 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Document struct {
+	Type        string
+	Sequence    string
+	FileName    string
+	Description string
+	Content     []byte
+}
+
+func (d *Document) GetExtension() (string, error) {
+	return (&file{Name: d.FileName}).GetExtension()
+}
+
+// ReadSubmission downloads the complete submission text file of a filing and
+// hands every document to handle as soon as it is parsed, so only one
+// document is held in memory at a time. The header of the submission is
+// returned like GetHeader returns it.
+func (api *API) ReadSubmission(ctx context.Context, cik string, fil *Filing, handle func(doc *Document) error) (*Header, error) {
+	body, err := api.OpenFile(ctx, cik, fil, fil.secID+".txt")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return parseSubmission(body, handle)
+}
+
+func parseSubmission(r io.Reader, handle func(doc *Document) error) (*Header, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var header *Header
+	var headerLines []string
+	// submissions before 2001 have an IMS-HEADER instead of a SEC-HEADER
+	var headerEnd string
+	var doc *Document
+	var text []string
+	inHeader, inText := false, false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) < 1 && err == io.EOF {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case inText:
+			if line == "</TEXT>" {
+				inText = false
+				content, decErr := decodeText(text)
+				if decErr != nil {
+					return nil, fmt.Errorf("%w, document %s, %s", ErrMalformed, doc.Sequence, decErr.Error())
+				}
+				doc.Content = content
+				text = nil
+			} else {
+				text = append(text, line)
+			}
+		case inHeader:
+			if line == headerEnd {
+				inHeader = false
+				var hdrErr error
+				header, hdrErr = parseHeader(textHeaderToSGML(headerLines))
+				if hdrErr != nil {
+					return nil, hdrErr
+				}
+			} else {
+				headerLines = append(headerLines, line)
+			}
+		case strings.HasPrefix(line, "<SEC-HEADER>"):
+			inHeader, headerEnd = true, "</SEC-HEADER>"
+		case strings.HasPrefix(line, "<IMS-HEADER>"):
+			inHeader, headerEnd = true, "</IMS-HEADER>"
+		case line == "<DOCUMENT>":
+			doc = &Document{}
+		case line == "</DOCUMENT>":
+			if doc == nil {
+				return nil, fmt.Errorf("%w, unexpected end of document", ErrMalformed)
+			}
+			if err := handle(doc); err != nil {
+				return nil, err
+			}
+			doc = nil
+		case doc != nil && line == "<TEXT>":
+			inText = true
+		case doc != nil && strings.HasPrefix(line, "<TYPE>"):
+			doc.Type = strings.TrimSpace(strings.TrimPrefix(line, "<TYPE>"))
+		case doc != nil && strings.HasPrefix(line, "<SEQUENCE>"):
+			doc.Sequence = strings.TrimSpace(strings.TrimPrefix(line, "<SEQUENCE>"))
+		case doc != nil && strings.HasPrefix(line, "<FILENAME>"):
+			doc.FileName = strings.TrimSpace(strings.TrimPrefix(line, "<FILENAME>"))
+		case doc != nil && strings.HasPrefix(line, "<DESCRIPTION>"):
+			doc.Description = strings.TrimSpace(strings.TrimPrefix(line, "<DESCRIPTION>"))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if inHeader || inText || doc != nil {
+		return nil, fmt.Errorf("%w, submission ended unexpectedly", ErrMalformed)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("%w, submission has no SEC-HEADER or IMS-HEADER", ErrMalformed)
+	}
+	return header, nil
+}
+
+var textWrappers = []string{"XBRL", "XML", "PDF", "JSON"}
+
+// decodeText strips the wrapper tags EDGAR puts around XBRL, XML and PDF
+// content and decodes uuencoded binaries.
+func decodeText(lines []string) ([]byte, error) {
+	for _, wrapper := range textWrappers {
+		if len(lines) > 1 && lines[0] == "<"+wrapper+">" && lines[len(lines)-1] == "</"+wrapper+">" {
+			lines = lines[1 : len(lines)-1]
+			break
+		}
+	}
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "begin ") {
+		return uudecode(lines)
+	}
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	return []byte(content), nil
+}
+
+func uudecode(lines []string) ([]byte, error) {
+	var out bytes.Buffer
+	for _, line := range lines[1:] {
+		if line == "end" {
+			return out.Bytes(), nil
+		}
+		if len(line) < 1 {
+			continue
+		}
+		n := int((line[0] - ' ') & 63)
+		if n == 0 {
+			continue
+		}
+		data := line[1:]
+		decoded := make([]byte, 0, n+2)
+		for i := 0; i < len(data); i += 4 {
+			var group [4]byte
+			for j := 0; j < 4; j++ {
+				if i+j < len(data) {
+					group[j] = (data[i+j] - ' ') & 63
+				}
+			}
+			decoded = append(
+				decoded,
+				group[0]<<2|group[1]>>4,
+				group[1]<<4|group[2]>>2,
+				group[2]<<6|group[3],
+			)
+		}
+		if len(decoded) < n {
+			return nil, errors.New("uuencoded line is shorter than its length")
+		}
+		out.Write(decoded[:n])
+	}
+	return nil, errors.New("uuencoded content has no end")
+}
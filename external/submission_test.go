@@ -0,0 +1,225 @@
+package external
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSubmission = `<SEC-DOCUMENT>0000320193-23-000106.txt : 20231103
+<SEC-HEADER>0000320193-23-000106.hdr.sgml : 20231103
+<ACCEPTANCE-DATETIME>20231102180827
+ACCESSION NUMBER:		0000320193-23-000106
+CONFORMED SUBMISSION TYPE:	10-K
+PUBLIC DOCUMENT COUNT:		3
+CONFORMED PERIOD OF REPORT:	20230930
+FILED AS OF DATE:		20231103
+
+FILER:
+
+	COMPANY DATA:	
+		COMPANY CONFORMED NAME:			Apple Inc.
+		CENTRAL INDEX KEY:			0000320193
+		STANDARD INDUSTRIAL CLASSIFICATION:	ELECTRONIC COMPUTERS [3571]
+		STATE OF INCORPORATION:			CA
+
+	FILING VALUES:
+		FORM TYPE:		10-K
+		SEC ACT:		1934 Act
+		SEC FILE NUMBER:	001-36743
+
+	BUSINESS ADDRESS:	
+		STREET 1:		ONE APPLE PARK WAY
+		CITY:			CUPERTINO
+		BUSINESS PHONE:		(408) 996-1010
+
+SUBJECT COMPANY:
+
+	COMPANY DATA:	
+		COMPANY CONFORMED NAME:			Target Corp
+		CENTRAL INDEX KEY:			0000027419
+</SEC-HEADER>
+<DOCUMENT>
+<TYPE>10-K
+<SEQUENCE>1
+<FILENAME>aapl-20230930.htm
+<DESCRIPTION>10-K
+<TEXT>
+<XBRL>
+<html><body>FORM 10-K</body></html>
+</XBRL>
+</TEXT>
+</DOCUMENT>
+<DOCUMENT>
+<TYPE>EX-21.1
+<SEQUENCE>2
+<FILENAME>a10-kexhibit2112023.htm
+<DESCRIPTION>EX-21.1
+<TEXT>
+<html><body>Subsidiaries</body></html>
+</TEXT>
+</DOCUMENT>
+<DOCUMENT>
+<TYPE>GRAPHIC
+<SEQUENCE>3
+<FILENAME>g1.jpg
+<TEXT>
+begin 644 g1.jpg
+%:&5L;&\
+end
+</TEXT>
+</DOCUMENT>
+</SEC-DOCUMENT>
+`
+
+func TestParseSubmission(t *testing.T) {
+	var docs []*Document
+	header, err := parseSubmission(strings.NewReader(testSubmission), func(doc *Document) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.AccessionNumber != "0000320193-23-000106" {
+		t.Errorf("got accession number %s, want 0000320193-23-000106", header.AccessionNumber)
+	}
+	if header.Type != "10-K" {
+		t.Errorf("got type %s, want 10-K", header.Type)
+	}
+	msg, ok := checkNullTime(header.PeriodOfReport, sqlTime(time.Date(2023, time.September, 30, 0, 0, 0, 0, time.UTC)))
+	if !ok {
+		t.Errorf("PeriodOfReport " + msg)
+	}
+	msg, ok = checkNullTime(header.AcceptanceDateTime, sqlTime(time.Date(2023, time.November, 2, 18, 8, 27, 0, time.UTC)))
+	if !ok {
+		t.Errorf("AcceptanceDateTime " + msg)
+	}
+	if len(header.Parties) != 2 {
+		t.Fatalf("got %d parties, want 2", len(header.Parties))
+	}
+	filer := header.Parties[0]
+	if filer.Role != "FILER" || filer.Name != "Apple Inc." || filer.CIK != "0000320193" || filer.SIC != "3571" {
+		t.Errorf("got filer %s %s %s SIC %s", filer.Role, filer.Name, filer.CIK, filer.SIC)
+	}
+	if filer.Act != "34" || filer.FileNumber != "001-36743" || filer.StateOfIncorporation != "CA" {
+		t.Errorf("got act %s file number %s state %s", filer.Act, filer.FileNumber, filer.StateOfIncorporation)
+	}
+	if filer.BusinessAddress == nil || filer.BusinessAddress.Street1 != "ONE APPLE PARK WAY" || filer.BusinessAddress.Phone != "(408) 996-1010" {
+		t.Errorf("got business address %+v", filer.BusinessAddress)
+	}
+	if header.Parties[1].Role != "SUBJECT-COMPANY" || header.Parties[1].Name != "Target Corp" {
+		t.Errorf("got subject company %s %s", header.Parties[1].Role, header.Parties[1].Name)
+	}
+	want := []*Document{
+		{
+			Type:        "10-K",
+			Sequence:    "1",
+			FileName:    "aapl-20230930.htm",
+			Description: "10-K",
+			Content:     []byte("<html><body>FORM 10-K</body></html>\n"),
+		},
+		{
+			Type:        "EX-21.1",
+			Sequence:    "2",
+			FileName:    "a10-kexhibit2112023.htm",
+			Description: "EX-21.1",
+			Content:     []byte("<html><body>Subsidiaries</body></html>\n"),
+		},
+		{Type: "GRAPHIC", Sequence: "3", FileName: "g1.jpg", Content: []byte("hello")},
+	}
+	if len(docs) != len(want) {
+		t.Fatalf("got %d documents, want %d", len(docs), len(want))
+	}
+	for i, got := range docs {
+		if got.Type != want[i].Type || got.Sequence != want[i].Sequence {
+			t.Errorf("got type %s sequence %s, want %s %s", got.Type, got.Sequence, want[i].Type, want[i].Sequence)
+		}
+		if got.FileName != want[i].FileName || got.Description != want[i].Description {
+			t.Errorf("got file %s description %s, want %s %s", got.FileName, got.Description, want[i].FileName, want[i].Description)
+		}
+		if !bytes.Equal(got.Content, want[i].Content) {
+			t.Errorf("got content %q, want %q", got.Content, want[i].Content)
+		}
+	}
+}
+
+func TestParseSubmissionItems(t *testing.T) {
+	input := strings.Replace(testSubmission, "FILED AS OF DATE:\t\t20231103\n", `FILED AS OF DATE:		20231103
+ITEM INFORMATION:		Departure of Directors or Certain Officers
+ITEM INFORMATION:		Financial Statements and Exhibits
+`, 1)
+	header, err := parseSubmission(strings.NewReader(input), func(doc *Document) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header.Items) > 0 {
+		t.Errorf("got items %v, want descriptions of the text header left out", header.Items)
+	}
+	if header.Type != "10-K" {
+		t.Errorf("got type %s, want 10-K", header.Type)
+	}
+}
+
+func TestParseSubmissionIMSHeader(t *testing.T) {
+	input := strings.ReplaceAll(testSubmission, "SEC-HEADER>", "IMS-HEADER>")
+	count := 0
+	header, err := parseSubmission(strings.NewReader(input), func(doc *Document) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.AccessionNumber != "0000320193-23-000106" || len(header.Parties) != 2 {
+		t.Errorf("got accession number %s with %d parties", header.AccessionNumber, len(header.Parties))
+	}
+	if count != 3 {
+		t.Errorf("got %d documents, want 3", count)
+	}
+}
+
+func TestParseSubmissionErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+	}{
+		{"Truncated document", strings.Split(testSubmission, "</TEXT>")[0]},
+		{"Missing header", "<SEC-DOCUMENT>\n</SEC-DOCUMENT>\n"},
+		{"Mismatched header end", "<IMS-HEADER>\n</SEC-HEADER>\n"},
+		{"Unterminated uuencoding", "<SEC-HEADER>\n</SEC-HEADER>\n<DOCUMENT>\n<TEXT>\nbegin 644 a.jpg\n%:&5L;&\\`\n</TEXT>\n</DOCUMENT>\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseSubmission(strings.NewReader(test.input), func(doc *Document) error { return nil })
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("got error %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestUudecode(t *testing.T) {
+	var tests = []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"Short line", []string{"begin 644 a", "%:&5L;&\\`", "`", "end"}, "hello"},
+		{"Two lines", []string{"begin 644 a", "#86)C", "#9&5F", "`", "end"}, "abcdef"},
+		{"Empty", []string{"begin 644 a", "`", "end"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := uudecode(test.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
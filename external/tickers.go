@@ -0,0 +1,135 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Ticker struct {
+	CIK      string
+	Name     string
+	Symbol   string
+	Exchange string
+}
+
+// NormalizeCIK turns a CIK like "320193" or "CIK0000320193" into the zero
+// padded 10 digit form EDGAR uses in its submissions URLs.
+func NormalizeCIK(cik string) (string, error) {
+	cik = strings.TrimSpace(cik)
+	if len(cik) > 3 && strings.EqualFold(cik[:3], "CIK") {
+		cik = cik[3:]
+	}
+	if len(cik) < 1 || len(cik) > 10 {
+		return "", fmt.Errorf("Invalid CIK '%s', must have 1 to 10 digits", cik)
+	}
+	for _, r := range cik {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("Invalid CIK '%s', must have 1 to 10 digits", cik)
+		}
+	}
+	return strings.Repeat("0", 10-len(cik)) + cik, nil
+}
+
+// GetTickers downloads the ticker to CIK mapping EDGAR publishes for all
+// companies with a listed security.
+func (api *API) GetTickers(ctx context.Context) ([]*Ticker, error) {
+	data, err := api.fetch(ctx, api.tickersURL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTickers(data)
+}
+
+// LoadTickers reads a local copy of company_tickers.json or
+// company_tickers_exchange.json.
+func LoadTickers(path string) ([]*Ticker, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Could not read tickers file, " + err.Error())
+	}
+	return ParseTickers(data)
+}
+
+// ParseTickers accepts both formats of the SEC ticker files, the object keyed
+// by row number of company_tickers.json and the fields and data arrays of
+// company_tickers_exchange.json.
+func ParseTickers(data []byte) ([]*Ticker, error) {
+	exchRes := &tickersExchangeResponse{}
+	if err := json.Unmarshal(data, exchRes); err == nil && len(exchRes.Fields) > 0 {
+		return transformTickersExchange(exchRes)
+	}
+	tickRes := map[string]tickerEntry{}
+	if err := json.Unmarshal(data, &tickRes); err != nil {
+		return nil, malformed("tickerEntry", err)
+	}
+	keys := make([]int, 0, len(tickRes))
+	for k := range tickRes {
+		n, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w, unexpected ticker row '%s'", ErrMalformed, k)
+		}
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	tickers := make([]*Ticker, 0, len(keys))
+	for _, k := range keys {
+		v := tickRes[strconv.Itoa(k)]
+		cik, err := NormalizeCIK(strconv.FormatInt(v.CIK, 10))
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s", ErrMalformed, err.Error())
+		}
+		tickers = append(tickers, &Ticker{CIK: cik, Name: v.Title, Symbol: v.Ticker})
+	}
+	return tickers, nil
+}
+
+func transformTickersExchange(data *tickersExchangeResponse) ([]*Ticker, error) {
+	idx := map[string]int{"cik": -1, "name": -1, "ticker": -1, "exchange": -1}
+	for i, field := range data.Fields {
+		idx[field] = i
+	}
+	if idx["cik"] < 0 || idx["ticker"] < 0 {
+		return nil, fmt.Errorf("%w, tickers file has no cik or ticker field", ErrMalformed)
+	}
+	tickers := make([]*Ticker, 0, len(data.Data))
+	for _, row := range data.Data {
+		if len(row) != len(data.Fields) {
+			return nil, fmt.Errorf("%w, tickers row has %d values for %d fields", ErrMalformed, len(row), len(data.Fields))
+		}
+		var rawCIK int64
+		if err := json.Unmarshal(row[idx["cik"]], &rawCIK); err != nil {
+			return nil, malformed("tickersExchangeResponse", err)
+		}
+		cik, err := NormalizeCIK(strconv.FormatInt(rawCIK, 10))
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s", ErrMalformed, err.Error())
+		}
+		t := &Ticker{CIK: cik}
+		for field, target := range map[string]*string{"name": &t.Name, "ticker": &t.Symbol, "exchange": &t.Exchange} {
+			if idx[field] >= 0 {
+				// exchange is null for some over the counter securities
+				json.Unmarshal(row[idx[field]], target)
+			}
+		}
+		tickers = append(tickers, t)
+	}
+	return tickers, nil
+}
+
+// FindTicker looks up a ticker symbol case insensitively. EDGAR writes share
+// classes with a dash, so "BRK.B" finds "BRK-B".
+func FindTicker(tickers []*Ticker, symbol string) *Ticker {
+	symbol = strings.ReplaceAll(strings.TrimSpace(symbol), ".", "-")
+	for _, t := range tickers {
+		if strings.EqualFold(t.Symbol, symbol) {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package external
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeCIK(t *testing.T) {
+	var tests = []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"320193", "0000320193", nil},
+		{"0000320193", "0000320193", nil},
+		{"CIK0000320193", "0000320193", nil},
+		{" 1067983 ", "0001067983", nil},
+		{"", "", errors.New("")},
+		{"AAPL", "", errors.New("")},
+		{"12345678901", "", errors.New("")},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := NormalizeCIK(test.input)
+			if (err != nil) != (test.err != nil) {
+				t.Errorf("got error %v, want error %v", err, test.err)
+				return
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTickers(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input []byte
+		err   error
+		want  []Ticker
+	}{
+		{
+			"Company tickers",
+			[]byte(`{
+				"1":{"cik_str":789019,"ticker":"MSFT","title":"MICROSOFT CORP"},
+				"0":{"cik_str":320193,"ticker":"AAPL","title":"Apple Inc."}
+			}`),
+			nil,
+			[]Ticker{
+				{CIK: "0000320193", Name: "Apple Inc.", Symbol: "AAPL"},
+				{CIK: "0000789019", Name: "MICROSOFT CORP", Symbol: "MSFT"},
+			},
+		},
+		{
+			"Company tickers with exchange",
+			[]byte(`{
+				"fields":["cik","name","ticker","exchange"],
+				"data":[[320193,"Apple Inc.","AAPL","Nasdaq"],[1067983,"BERKSHIRE HATHAWAY INC","BRK-B",null]]
+			}`),
+			nil,
+			[]Ticker{
+				{CIK: "0000320193", Name: "Apple Inc.", Symbol: "AAPL", Exchange: "Nasdaq"},
+				{CIK: "0001067983", Name: "BERKSHIRE HATHAWAY INC", Symbol: "BRK-B"},
+			},
+		},
+		{
+			"Row with missing values",
+			[]byte(`{"fields":["cik","name","ticker","exchange"],"data":[[320193,"Apple Inc."]]}`),
+			errors.New(""),
+			nil,
+		},
+		{"Malformed", []byte(`{"0":`), errors.New(""), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseTickers(test.input)
+			if (err != nil) != (test.err != nil) {
+				t.Errorf("got error %v, want error %v", err, test.err)
+				return
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("got %d tickers, want %d", len(got), len(test.want))
+				return
+			}
+			for i, v := range got {
+				if *v != test.want[i] {
+					t.Errorf("got %+v, want %+v", *v, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFindTicker(t *testing.T) {
+	tickers := []*Ticker{{CIK: "0000320193", Symbol: "AAPL"}, {CIK: "0001067983", Symbol: "BRK-B"}}
+	if got := FindTicker(tickers, "aapl"); got == nil || got.CIK != "0000320193" {
+		t.Errorf("got %v, want AAPL", got)
+	}
+	if got := FindTicker(tickers, "BRK.B"); got == nil || got.CIK != "0001067983" {
+		t.Errorf("got %v, want BRK-B", got)
+	}
+	if got := FindTicker(tickers, "MSFT"); got != nil {
+		t.Errorf("got %v, want none", got)
+	}
+}
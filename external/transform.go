@@ -2,41 +2,60 @@ package external
 
 import (
 	"database/sql"
+	"strconv"
 	"time"
 )
 
-func transformFilings(data *filingsResponse) []*Filing {
+// transformFilings validates a page of the submissions JSON before turning
+// its valid rows into filings.
+func transformFilings(data *recent, policy *FormPolicy) ([]*Filing, []*Skipped, []*Rejected) {
+	data, rejected := validateRecent(data)
 	var filings []*Filing
-	for i, v := range data.Filings.Recent.Form {
-		if v != "10-K" && v != "10-Q" {
-			continue
-		}
-		test := &file{Name: data.Filings.Recent.PrimDoc[i]}
-		check, err := test.GetExtension()
-		if err != nil {
-			continue
+	var skipped []*Skipped
+	for i, v := range data.Form {
+		reason := policy.check(v, data.PrimDoc[i])
+		if len(reason) < 1 {
+			reason = policy.checkSize(data.Size[i])
 		}
-		if check != ".htm" {
+		if len(reason) > 0 {
+			skipped = append(skipped, &Skipped{
+				SecID:  data.AccessNumber[i],
+				Form:   v,
+				Reason: reason,
+			})
 			continue
 		}
 		fil := &Filing{
-			secID:      data.Filings.Recent.AccessNumber[i],
-			mainFile:   data.Filings.Recent.PrimDoc[i],
+			secID:      data.AccessNumber[i],
+			mainFile:   data.PrimDoc[i],
 			Form:       v,
-			FilingDate: parseNullTime("2006-01-02", data.Filings.Recent.FilingDate[i]),
-			AcceptDate: parseNullTime(time.RFC3339, data.Filings.Recent.AcceptDate[i]),
-			ReportDate: parseNullTime("2006-01-02", data.Filings.Recent.ReportDate[i]),
+			FilingDate: parseNullTime("2006-01-02", data.FilingDate[i]),
+			AcceptDate: parseNullTime(time.RFC3339, data.AcceptDate[i]),
+			ReportDate: parseNullTime("2006-01-02", data.ReportDate[i]),
+
+			PrimaryDocDescription: data.PrimDocDesc[i],
+			Act:                   data.Act[i],
+			FileNumber:            data.FileNumber[i],
+			FilmNumber:            data.FilmNumber[i],
+			Items:                 data.Items[i],
+			Size:                  data.Size[i],
+			IsXBRL:                data.IsXBRL[i] == 1,
+			IsInlineXBRL:          data.IsInlineXBRL[i] == 1,
 		}
 		filings = append(filings, fil)
 	}
-	return filings
+	return filings, skipped, rejected
 }
 
 func transformFiles(data *filesResponse) []*file {
 	var files []*file
 	for _, v := range data.Dir.Items {
+		if v.Type == "folder.gif" {
+			continue
+		}
 		fil := &file{
 			Name:         v.Name,
+			Size:         parseSize(v.Size),
 			LastModified: parseNullTime("2006-01-02 15:04:05", v.LastModified),
 		}
 		files = append(files, fil)
@@ -44,6 +63,25 @@ func transformFiles(data *filesResponse) []*file {
 	return files
 }
 
+// valueAt returns the zero value for fields missing from older submissions.
+func valueAt[T any](values []T, i int) T {
+	var zero T
+	if i >= len(values) {
+		return zero
+	}
+	return values[i]
+}
+
+// parseSize returns -1 for files EDGAR lists without a size, which is what
+// FileStorage.PutStream expects for an unknown length.
+func parseSize(value string) int64 {
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return -1
+	}
+	return size
+}
+
 func parseNullTime(layout string, value string) sql.NullTime {
 	t, err := time.Parse(layout, value)
 	if err != nil {
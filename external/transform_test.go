@@ -185,7 +185,7 @@ func TestTransformFilings(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			filings := transformFilings(test.input)
+			filings, _, _ := transformFilings(&test.input.Filings.Recent, DefaultFormPolicy())
 			for i, got := range filings {
 				if got.secID != test.want[i].secID {
 					t.Errorf("got: %s, want: %s", got.secID, test.want[i].secID)
@@ -223,6 +223,199 @@ func TestTransformFilings(t *testing.T) {
 	}
 }
 
+func TestTransformFilingDetails(t *testing.T) {
+	input := &filingsResponse{
+		Filings: filings{
+			Recent: recent{
+				AccessNumber: []string{"0000320193-23-000106", "0000320193-23-000077"},
+				AcceptDate:   []string{"2023-11-02T18:08:27.000Z", "2023-08-03T18:04:43.000Z"},
+				FilingDate:   []string{"2023-11-03", "2023-08-04"},
+				ReportDate:   []string{"2023-09-30", "2023-07-01"},
+				Form:         []string{"10-K", "10-Q"},
+				PrimDoc:      []string{"aapl-20230930.htm", "aapl-20230701.htm"},
+				PrimDocDesc:  []string{"10-K"},
+				Act:          []string{"34", "34"},
+				FileNumber:   []string{"001-36743", "001-36743"},
+				FilmNumber:   []string{"231373899", "231140185"},
+				Items:        []string{"", "2.02,9.01"},
+				Size:         []int64{9500986, 5327845},
+				IsXBRL:       []int{1, 0},
+				IsInlineXBRL: []int{1, 0},
+			},
+		},
+	}
+	want := []*Filing{
+		{
+			PrimaryDocDescription: "10-K",
+			Act:                   "34",
+			FileNumber:            "001-36743",
+			FilmNumber:            "231373899",
+			Size:                  9500986,
+			IsXBRL:                true,
+			IsInlineXBRL:          true,
+		},
+		{
+			Act:        "34",
+			FileNumber: "001-36743",
+			FilmNumber: "231140185",
+			Items:      "2.02,9.01",
+			Size:       5327845,
+		},
+	}
+	filings, _, _ := transformFilings(&input.Filings.Recent, DefaultFormPolicy())
+	if len(filings) != len(want) {
+		t.Fatalf("got %d filings, want %d", len(filings), len(want))
+	}
+	for i, got := range filings {
+		got.secID, got.mainFile = "", ""
+		got.FilingDate, got.AcceptDate, got.ReportDate = sql.NullTime{}, sql.NullTime{}, sql.NullTime{}
+		got.Form = ""
+		if *got != *want[i] {
+			t.Errorf("filing %d got: %+v, want: %+v", i, *got, *want[i])
+		}
+	}
+}
+
+func TestValidateRecent(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    *recent
+		valid    []string
+		rejected []string
+	}{
+		{
+			"Valid rows with missing optional values",
+			&recent{
+				AccessNumber: []string{"0000320193-23-000106", "0000320193-23-000105"},
+				FilingDate:   []string{"2023-11-03", "2023-11-02"},
+				AcceptDate:   []string{"2023-11-02T18:08:27.000Z", ""},
+				ReportDate:   []string{"2023-09-30", ""},
+				Form:         []string{"10-K", "8-K"},
+				PrimDoc:      []string{"aapl-20230930.htm", "aapl-20231102.htm"},
+				Size:         []int64{9781512},
+			},
+			[]string{"0000320193-23-000106", "0000320193-23-000105"},
+			nil,
+		},
+		{
+			"Truncated arrays",
+			&recent{
+				AccessNumber: []string{"0000320193-23-000106", "0000320193-23-000105"},
+				FilingDate:   []string{"2023-11-03", "2023-11-02"},
+				AcceptDate:   []string{"2023-11-02T18:08:27.000Z", "2023-11-02T18:04:07.000Z"},
+				ReportDate:   []string{"2023-09-30"},
+				Form:         []string{"10-K", "8-K"},
+				PrimDoc:      []string{"aapl-20230930.htm"},
+			},
+			[]string{"0000320193-23-000106"},
+			[]string{"missing reportDate, primaryDocument"},
+		},
+		{
+			"Rows beyond the form array",
+			&recent{
+				AccessNumber: []string{"0000320193-23-000106", "0000320193-23-000105"},
+				FilingDate:   []string{"2023-11-03", "2023-11-02"},
+				AcceptDate:   []string{"2023-11-02T18:08:27.000Z", "2023-11-02T18:04:07.000Z"},
+				ReportDate:   []string{"2023-09-30", ""},
+				Form:         []string{"10-K"},
+				PrimDoc:      []string{"aapl-20230930.htm", "aapl-20231102.htm"},
+			},
+			[]string{"0000320193-23-000106"},
+			[]string{"missing form"},
+		},
+		{
+			"Invalid values",
+			&recent{
+				AccessNumber: []string{"000032019323000106", "0000320193-23-000105", "0000320193-23-000104", "0000320193-23-000103"},
+				FilingDate:   []string{"2023-11-03", "11/02/2023", "2023-11-02", "2023-11-01"},
+				AcceptDate:   []string{"", "", "2023-11-02 16:30", ""},
+				ReportDate:   []string{"", "", "", ""},
+				Form:         []string{"10-K", "8-K", "8-K", " "},
+				PrimDoc:      []string{"a.htm", "b.htm", "c.htm", "d.htm"},
+			},
+			nil,
+			[]string{
+				"invalid accession number '000032019323000106'",
+				"invalid filing date '11/02/2023'",
+				"invalid acceptance date '2023-11-02 16:30'",
+				"missing form",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valid, rejected := validateRecent(test.input)
+			if fmt.Sprint(valid.AccessNumber) != fmt.Sprint(test.valid) {
+				t.Errorf("got valid rows %v, want %v", valid.AccessNumber, test.valid)
+			}
+			for _, values := range [][]int{{len(valid.Form), len(valid.PrimDocDesc)}, {len(valid.Form), len(valid.Size)}} {
+				if values[0] != values[1] {
+					t.Errorf("got %d forms but %d optional values, want parallel arrays", values[0], values[1])
+				}
+			}
+			var reasons []string
+			for _, rj := range rejected {
+				reasons = append(reasons, rj.Reason)
+				if len(rj.Row) < 1 {
+					t.Errorf("raw values of rejected row %s missing", rj.SecID)
+				}
+			}
+			if fmt.Sprint(reasons) != fmt.Sprint(test.rejected) {
+				t.Errorf("got rejected rows %v, want %v", reasons, test.rejected)
+			}
+		})
+	}
+}
+
+func TestTransformCompany(t *testing.T) {
+	input := &filingsResponse{
+		Name:                 "Apple Inc.",
+		CIK:                  "0000320193",
+		SIC:                  "3571",
+		SICDescription:       "Electronic Computers",
+		Tickers:              []string{"AAPL"},
+		Exchanges:            []string{"Nasdaq"},
+		EIN:                  "942404110",
+		FiscalYearEnd:        "0930",
+		StateOfIncorporation: "CA",
+		Phone:                "(408) 996-1010",
+		Addresses: addresses{
+			Business: &address{Street1: "ONE APPLE PARK WAY", City: "CUPERTINO", StateOrCountry: "CA", ZipCode: "95014"},
+		},
+		FormerNames: []formerName{
+			{Name: "APPLE COMPUTER INC", From: "1994-01-26T00:00:00.000Z", To: "2007-01-04T00:00:00.000Z"},
+			{Name: "APPLE INC", From: "2007-01-10T00:00:00.000Z", To: ""},
+		},
+	}
+	got := transformCompany(input)
+	if got.Name != "Apple Inc." || got.CIK != "0000320193" || got.SIC != "3571" || got.FiscalYearEnd != "0930" {
+		t.Errorf("got %+v", *got)
+	}
+	if len(got.Tickers) != 1 || got.Tickers[0] != "AAPL" {
+		t.Errorf("got tickers %v, want [AAPL]", got.Tickers)
+	}
+	if got.MailAddress != nil {
+		t.Errorf("got mail address %+v, want none", *got.MailAddress)
+	}
+	want := Address{Street1: "ONE APPLE PARK WAY", City: "CUPERTINO", State: "CA", Zip: "95014"}
+	if got.BusinessAddress == nil || *got.BusinessAddress != want {
+		t.Errorf("got business address %+v, want %+v", got.BusinessAddress, want)
+	}
+	if len(got.FormerNames) != 2 {
+		t.Fatalf("got %d former names, want 2", len(got.FormerNames))
+	}
+	msg, ok := checkNullTime(got.FormerNames[0].To, sql.NullTime{
+		Time:  time.Date(2007, time.January, 4, 0, 0, 0, 0, time.UTC),
+		Valid: true,
+	})
+	if !ok {
+		t.Errorf("former name To " + msg)
+	}
+	if got.FormerNames[1].To.Valid {
+		t.Errorf("got end of current name %s, want none", got.FormerNames[1].To.Time)
+	}
+}
+
 func TestTransformFiles(t *testing.T) {
 	var tests = []struct {
 		name  string
@@ -234,6 +427,18 @@ func TestTransformFiles(t *testing.T) {
 			&filesResponse{Dir: directory{Items: []item{{Name: "Test", LastModified: ""}}}},
 			[]*file{{Name: "Test", LastModified: sql.NullTime{Valid: false}}},
 		},
+		{
+			"Folder entries are skipped",
+			&filesResponse{
+				Dir: directory{
+					Items: []item{
+						{Name: "images", Type: "folder.gif"},
+						{Name: "Test", Type: "text.gif", Size: "1024"},
+					},
+				},
+			},
+			[]*file{{Name: "Test", Size: 1024, LastModified: sql.NullTime{Valid: false}}},
+		},
 		{
 			"One file with valid timestamp",
 			&filesResponse{
@@ -364,10 +569,17 @@ func TestTransformFiles(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			files := transformFiles(test.input)
+			if len(files) != len(test.want) {
+				t.Errorf("got %d files, want %d", len(files), len(test.want))
+				return
+			}
 			for i, got := range files {
 				if got.Name != test.want[i].Name {
 					t.Errorf("got: %s, want: %s", got.Name, test.want[i].Name)
 				}
+				if test.want[i].Size != 0 && got.Size != test.want[i].Size {
+					t.Errorf("got size: %d, want: %d", got.Size, test.want[i].Size)
+				}
 				msg, ok := checkNullTime(got.LastModified, test.want[i].LastModified)
 				if !ok {
 					t.Errorf(fmt.Sprintf("for file: %s, ", got.Name) + msg)
@@ -447,3 +659,7 @@ func checkNullTime(got sql.NullTime, want sql.NullTime) (string, bool) {
 	}
 	return "", true
 }
+
+func sqlTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
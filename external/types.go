@@ -1,13 +1,54 @@
 package external
 
+import "encoding/json"
+
 type filingsResponse struct {
-	Name    string  `json:"name"`
-	CIK     string  `json:"cik"`
-	Filings filings `json:"filings"`
+	Name                 string       `json:"name"`
+	CIK                  string       `json:"cik"`
+	EntityType           string       `json:"entityType"`
+	SIC                  string       `json:"sic"`
+	SICDescription       string       `json:"sicDescription"`
+	Tickers              []string     `json:"tickers"`
+	Exchanges            []string     `json:"exchanges"`
+	EIN                  string       `json:"ein"`
+	Category             string       `json:"category"`
+	FiscalYearEnd        string       `json:"fiscalYearEnd"`
+	StateOfIncorporation string       `json:"stateOfIncorporation"`
+	Addresses            addresses    `json:"addresses"`
+	Phone                string       `json:"phone"`
+	FormerNames          []formerName `json:"formerNames"`
+	Filings              filings      `json:"filings"`
+}
+
+type addresses struct {
+	Mailing  *address `json:"mailing"`
+	Business *address `json:"business"`
+}
+
+type address struct {
+	Street1        string `json:"street1"`
+	Street2        string `json:"street2"`
+	City           string `json:"city"`
+	StateOrCountry string `json:"stateOrCountry"`
+	ZipCode        string `json:"zipCode"`
+}
+
+type formerName struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type filings struct {
-	Recent recent `json:"recent"`
+	Recent recent        `json:"recent"`
+	Files  []filingsPage `json:"files"`
+}
+
+type filingsPage struct {
+	Name        string `json:"name"`
+	FilingCount int    `json:"filingCount"`
+	FilingFrom  string `json:"filingFrom"`
+	FilingTo    string `json:"filingTo"`
 }
 
 type recent struct {
@@ -17,6 +58,14 @@ type recent struct {
 	ReportDate   []string `json:"reportDate"`
 	Form         []string `json:"form"`
 	PrimDoc      []string `json:"primaryDocument"`
+	PrimDocDesc  []string `json:"primaryDocDescription"`
+	Act          []string `json:"act"`
+	FileNumber   []string `json:"fileNumber"`
+	FilmNumber   []string `json:"filmNumber"`
+	Items        []string `json:"items"`
+	Size         []int64  `json:"size"`
+	IsXBRL       []int    `json:"isXBRL"`
+	IsInlineXBRL []int    `json:"isInlineXBRL"`
 }
 
 type filesResponse struct {
@@ -29,5 +78,18 @@ type directory struct {
 
 type item struct {
 	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Size         string `json:"size"`
 	LastModified string `json:"last-modified"`
 }
+
+type tickerEntry struct {
+	CIK    int64  `json:"cik_str"`
+	Ticker string `json:"ticker"`
+	Title  string `json:"title"`
+}
+
+type tickersExchangeResponse struct {
+	Fields []string            `json:"fields"`
+	Data   [][]json.RawMessage `json:"data"`
+}
@@ -0,0 +1,137 @@
+package external
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrInvalidContent = errors.New("Invalid document content")
+	ErrBlockPage      = errors.New("EDGAR block page")
+)
+
+const (
+	minDocumentSize = 256
+	blockPageScan   = 16 * 1024
+)
+
+var blockPageSignatures = [][]byte{
+	[]byte("Request Rate Threshold Exceeded"),
+	[]byte("Undeclared Automated Tool"),
+	[]byte("SEC reserves the right to limit requests originating from undeclared automated tools"),
+}
+
+var extensionTypes = map[string][]string{
+	".htm":  {"text/html", "text/xml", "text/plain"},
+	".html": {"text/html", "text/xml", "text/plain"},
+	".xml":  {"text/xml", "application/xml", "text/plain"},
+	".xsd":  {"text/xml", "application/xml", "text/plain"},
+	".txt":  {"text/plain", "text/html", "text/xml"},
+	".pdf":  {"application/pdf"},
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".gif":  {"image/gif"},
+	".png":  {"image/png"},
+	".zip":  {"application/zip"},
+	".xlsx": {"application/zip", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+type ContentError struct {
+	Name   string
+	Reason string
+	Err    error
+}
+
+func (e *ContentError) Error() string {
+	return fmt.Sprintf("%s '%s', %s", e.Err.Error(), e.Name, e.Reason)
+}
+
+func (e *ContentError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes block pages match ErrInvalidContent too, so callers only
+// interested in whether the content can be stored need a single check.
+func (e *ContentError) Is(target error) bool {
+	return target == ErrInvalidContent
+}
+
+func checkBlockPage(name string, data []byte) error {
+	head := data
+	if len(head) > blockPageScan {
+		head = head[:blockPageScan]
+	}
+	for _, sig := range blockPageSignatures {
+		if bytes.Contains(head, sig) {
+			return &ContentError{Name: name, Reason: "found '" + string(sig) + "'", Err: ErrBlockPage}
+		}
+	}
+	return nil
+}
+
+// validateDocument checks that a downloaded document is plausibly what its
+// name says it is before it gets persisted. The content type reported by the
+// server and the sniffed content type both have to match the extension. Only
+// the primary document has a minimum size, exhibits and XBRL parts can be
+// tiny.
+func validateDocument(name string, primary bool, contentType string, data []byte) error {
+	if err := checkBlockPage(name, data); err != nil {
+		return err
+	}
+	if primary && len(data) < minDocumentSize {
+		return &ContentError{
+			Name:   name,
+			Reason: fmt.Sprintf("size of %d bytes is below minimum of %d", len(data), minDocumentSize),
+			Err:    ErrInvalidContent,
+		}
+	}
+	ext, err := (&file{Name: name}).GetExtension()
+	if err != nil {
+		return nil
+	}
+	expected, ok := extensionTypes[strings.ToLower(ext)]
+	if !ok {
+		return nil
+	}
+	if len(contentType) > 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && mediaType != "application/octet-stream" && !containsFold(expected, mediaType) {
+			return &ContentError{
+				Name:   name,
+				Reason: fmt.Sprintf("server content type '%s' does not match extension '%s'", mediaType, ext),
+				Err:    ErrInvalidContent,
+			}
+		}
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !containsFold(expected, sniffed) {
+		return &ContentError{
+			Name:   name,
+			Reason: fmt.Sprintf("content type '%s' does not match extension '%s'", sniffed, ext),
+			Err:    ErrInvalidContent,
+		}
+	}
+	return nil
+}
+
+// validateStream reads the beginning of a download to validate it and returns
+// a reader which still yields the complete content.
+func validateStream(name string, primary bool, contentType string, r io.ReadCloser) (io.ReadCloser, error) {
+	head := make([]byte, blockPageScan)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		r.Close()
+		return nil, err
+	}
+	head = head[:n]
+	if err := validateDocument(name, primary, contentType, head); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &stream{Reader: io.MultiReader(bytes.NewReader(head), r), closers: []io.Closer{r}}, nil
+}
@@ -0,0 +1,63 @@
+package external
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidateDocument(t *testing.T) {
+	blockPage := []byte(`<html><head><title>SEC.gov | Request Rate Threshold Exceeded</title></head>
+<body><h1>Your Request Originates from an Undeclared Automated Tool</h1></body></html>`)
+	pdf := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("0"), minDocumentSize)...)
+	text := bytes.Repeat([]byte("FORM 10-K "), 50)
+	xlsx := append([]byte("PK\x03\x04"), bytes.Repeat([]byte("0"), minDocumentSize)...)
+	var tests = []struct {
+		name        string
+		fileName    string
+		primary     bool
+		contentType string
+		data        []byte
+		err         error
+	}{
+		{"Valid HTML document", "k2004.htm", true, "text/html", testDocument, nil},
+		{"Valid HTML without content type", "k2004.htm", true, "", testDocument, nil},
+		{"Valid inline XBRL", "aapl-20230930.htm", true, "text/html", append([]byte(`<?xml version="1.0"?>`), testDocument...), nil},
+		{"Valid PDF", "report.pdf", true, "application/pdf", pdf, nil},
+		{"Valid text", "0000320193-23-000106.txt", true, "text/plain", text, nil},
+		{
+			"Valid spreadsheet",
+			"Financial_Report.xlsx",
+			false,
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			xlsx,
+			nil,
+		},
+		{"Unknown extension is not checked", "data.foo", true, "", text, nil},
+		{"Octet stream content type", "report.pdf", true, "application/octet-stream", pdf, nil},
+		{"Block page", "k2004.htm", true, "text/html", blockPage, ErrBlockPage},
+		{"Too small", "k2004.htm", true, "text/html", []byte(`<html></html>`), ErrInvalidContent},
+		{"Small exhibit", "ex21.htm", false, "text/html", []byte(`<html></html>`), nil},
+		{"Small XBRL schema", "aapl-20230930.xsd", false, "text/xml", []byte(`<?xml version="1.0"?><schema/>`), nil},
+		{"HTML served for PDF", "report.pdf", true, "text/html", testDocument, ErrInvalidContent},
+		{"PDF served as HTML", "k2004.htm", true, "", pdf, ErrInvalidContent},
+		{"Server content type mismatch", "k2004.htm", true, "image/png", testDocument, ErrInvalidContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateDocument(test.fileName, test.primary, test.contentType, test.data)
+			if test.err == nil {
+				if err != nil {
+					t.Errorf(err.Error())
+				}
+				return
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+			if !errors.Is(err, ErrInvalidContent) {
+				t.Errorf("expected %v to count as invalid content", err)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -12,56 +16,199 @@ import (
 	"github.com/sec-data-pipeline/extractor/storage"
 )
 
-var extractor *service.Extractor
-
 func main() {
-	err := extractor.Run()
+	// the first signal stops the run gracefully, a second one kills it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	extractor, err := newExtractor()
+	if err != nil {
+		panic(err)
+	}
+	err = extractor.Run(ctx)
+	if errors.Is(err, service.ErrInterrupted) {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 	if err != nil {
 		panic(err)
 	}
 }
 
-func init() {
-	var db storage.Database
-	var secrets storage.Secrets
-	var archive storage.FileStorage
-	var logger storage.Logger
-	var err error
-	region := os.Getenv("REGION")
-	if len(region) > 0 {
-		awsSession, err := session.NewSession(&aws.Config{
-			Region: aws.String(region),
-		})
+func runCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "companies":
+		return runCompanies(ctx, args[1:])
+	case "watch":
+		extractor, err := newExtractor()
 		if err != nil {
-			panic(err)
+			return err
 		}
+		return extractor.Watch(ctx)
+	case "verify":
+		return runVerify(ctx, args[1:])
+	case "audit":
+		return runAudit(ctx, args[1:])
+	default:
+		return errors.New(fmt.Sprintf("Unknown command '%s', usage: extractor [companies|watch|verify|audit]", args[0]))
+	}
+}
+
+// newSession returns nil when REGION is not set, the extractor then runs
+// locally with its configuration from the environment.
+func newSession() (*session.Session, error) {
+	region := os.Getenv("REGION")
+	if len(region) < 1 {
+		return nil, nil
+	}
+	return session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	})
+}
+
+// newDatabase connects to the database only, which is all managing the
+// tracked companies needs.
+func newDatabase(awsSession *session.Session) (storage.Database, error) {
+	var secrets storage.Secrets
+	var err error
+	if awsSession != nil {
 		secrets = storage.NewSecretsManager(awsSession, envOrPanic("SECRETS"))
-		archive = storage.NewS3Bucket(awsSession, envOrPanic("ARCHIVE_BUCKET"))
-		logger = storage.NewCloudWatch()
 	} else {
 		secrets, err = storage.NewEnvLoader()
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		archive = storage.NewFolder(envOrPanic("DEST"))
-		logger = storage.NewConsole()
 	}
 	params, err := secrets.GetConnParams()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	return storage.NewPostgres(params)
+}
+
+func newAPI() (*external.API, error) {
+	limiter, err := newRateLimiter()
+	if err != nil {
+		return nil, err
 	}
-	db, err = storage.NewPostgres(params)
+	clientCfg, err := newClientConfig()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	api := external.NewAPI()
-	extractor = service.NewExtractorService(api, db, archive, logger)
+	return external.NewAPI(clientCfg, limiter)
 }
 
-func envOrPanic(key string) string {
-	value := os.Getenv(key)
-	if len(value) < 1 {
-		panic(errors.New(fmt.Sprintf("Environment variable '%s' must be specified", key)))
+// newExtractor builds everything a run needs from the environment.
+func newExtractor() (*service.Extractor, error) {
+	awsSession, err := newSession()
+	if err != nil {
+		return nil, err
+	}
+	var archive storage.FileStorage
+	var logger storage.Logger
+	if awsSession != nil {
+		archive = storage.NewS3Bucket(awsSession, envOrPanic("ARCHIVE_BUCKET"))
+		logger = storage.NewCloudWatch()
+	} else {
+		archive = storage.NewFolder(envOrPanic("DEST"))
+		logger = storage.NewConsole()
+	}
+	db, err := newDatabase(awsSession)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := external.DefaultPolicyConfig()
+	if path := os.Getenv("FORM_POLICY"); len(path) > 0 {
+		policies, err = external.LoadPolicyConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	documents, err := external.ParseDocumentFilter(os.Getenv("DOCUMENTS"))
+	if err != nil {
+		return nil, err
+	}
+	xbrlDocuments, err := external.ParseDocumentFilter(os.Getenv("XBRL_DOCUMENTS"))
+	if err != nil {
+		return nil, err
+	}
+	maxFilingSize, err := envIntOrDefault("MAX_FILING_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	policies.LimitSize(int64(maxFilingSize))
+	source := os.Getenv("SOURCE")
+	switch source {
+	case "":
+		source = service.SourceIndex
+	case service.SourceIndex, service.SourceSubmission:
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment variable 'SOURCE' must be '%s' or '%s'", service.SourceIndex, service.SourceSubmission))
+	}
+	discovery := os.Getenv("DISCOVERY")
+	switch discovery {
+	case "":
+		discovery = service.DiscoveryCompanies
+	case service.DiscoveryCompanies, service.DiscoveryIndex:
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment variable 'DISCOVERY' must be '%s' or '%s'", service.DiscoveryCompanies, service.DiscoveryIndex))
+	}
+	indexFrom, indexTo, err := newIndexRange()
+	if err != nil {
+		return nil, err
+	}
+	watchInterval, err := envDurationOrDefault("WATCH_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	watchPages, err := envIntOrDefault("WATCH_PAGES", 5)
+	if err != nil {
+		return nil, err
+	}
+	gracePeriod, err := envDurationOrDefault("SHUTDOWN_GRACE_PERIOD", 25*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := envIntOrDefault("WORKERS", 1)
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := envIntOrDefault("DOWNLOADS", 0)
+	if err != nil {
+		return nil, err
+	}
+	opts := &service.Options{
+		Policies:  policies,
+		Backfill:  os.Getenv("BACKFILL") == "true",
+		Documents: documents,
+		Source:    source,
+		Headers:   os.Getenv("HEADERS") == "true",
+
+		XBRLDocuments: xbrlDocuments,
+
+		Discovery: discovery,
+		IndexFrom: indexFrom,
+		IndexTo:   indexTo,
+		Universe:  os.Getenv("UNIVERSE") == "true",
+
+		WatchInterval: watchInterval,
+		WatchPages:    watchPages,
+
+		GracePeriod: gracePeriod,
+
+		Workers:   workers,
+		Downloads: downloads,
+	}
+	api, err := newAPI()
+	if err != nil {
+		return nil, err
 	}
-	return value
+	return service.NewExtractorService(api, db, archive, logger, opts), nil
 }
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+// CompanyCoverage compares the filings EDGAR lists for a company with the
+// committed filings. Expected are the listed filings the run would extract.
+type CompanyCoverage struct {
+	CIK      string
+	Listed   int
+	Expected int
+	Stored   int
+	Coverage float64
+	// Accession numbers of expected filings which are not stored and of
+	// stored filings which EDGAR no longer lists.
+	Missing     []string
+	Disappeared []string
+}
+
+type AuditReport struct {
+	Companies []*CompanyCoverage
+	// Companies whose submissions could not be read.
+	Failed []string
+}
+
+// Audit compares every submissions page of the tracked companies with the
+// filings stored, flags the filings which disappeared from EDGAR and records
+// the coverage of each company.
+func (s *Extractor) Audit(ctx context.Context) (*AuditReport, error) {
+	companies, err := s.db.GetCompanies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*CompanyCoverage, len(companies))
+	err = s.forEach(len(companies), func(w *Extractor, i int) error {
+		cmp := companies[i]
+		cov, err := w.withLogPrefix("["+cmp.CIK+"] ").auditCompany(ctx, cmp.ID, cmp.CIK)
+		results[i] = cov
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditReport{}
+	for i, cov := range results {
+		if cov == nil {
+			report.Failed = append(report.Failed, companies[i].CIK)
+			continue
+		}
+		report.Companies = append(report.Companies, cov)
+	}
+	return report, nil
+}
+
+// auditCompany returns nil when the submissions of the company could not be
+// read and only errors which have to abort the audit.
+func (s *Extractor) auditCompany(ctx context.Context, cmpID int, cik string) (*CompanyCoverage, error) {
+	sub, err := s.api.GetSubmissions(ctx, cik, s.opts.Policies.For(cik), true)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, s.handleAPIError(cik, err)
+	}
+	got, err := s.db.GetFilingIDs(ctx, cmpID)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool)
+	for _, id := range got {
+		stored[id] = true
+	}
+	// filings skipped by the policy or rejected by validation are still
+	// listed, their accession numbers keep the dashes
+	listed := make(map[string]bool)
+	for _, sk := range sub.Skipped {
+		listed[strings.ReplaceAll(sk.SecID, "-", "")] = true
+	}
+	for _, rej := range sub.Rejected {
+		listed[strings.ReplaceAll(rej.SecID, "-", "")] = true
+	}
+	cov := &CompanyCoverage{CIK: cik, Coverage: 100}
+	for _, fil := range sub.Filings {
+		listed[fil.GetID()] = true
+		cov.Expected++
+		if stored[fil.GetID()] {
+			cov.Stored++
+			continue
+		}
+		cov.Missing = append(cov.Missing, fil.GetID())
+	}
+	for _, id := range got {
+		if !listed[id] {
+			cov.Disappeared = append(cov.Disappeared, id)
+		}
+	}
+	cov.Listed = len(listed)
+	if cov.Expected > 0 {
+		cov.Coverage = 100 * float64(cov.Stored) / float64(cov.Expected)
+	}
+	if err := s.db.MarkDisappeared(ctx, cmpID, cov.Disappeared); err != nil {
+		return nil, err
+	}
+	err = s.db.InsertCoverage(ctx, &storage.CoverageRecord{
+		CompanyID:   cmpID,
+		Listed:      cov.Listed,
+		Expected:    cov.Expected,
+		Stored:      cov.Stored,
+		Missing:     len(cov.Missing),
+		Disappeared: len(cov.Disappeared),
+		Coverage:    cov.Coverage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Log(fmt.Sprintf(
+		"Coverage of company '%s' is %.2f%%, %d of %d filings stored, %d disappeared from EDGAR",
+		cik,
+		cov.Coverage,
+		cov.Stored,
+		cov.Expected,
+		len(cov.Disappeared),
+	))
+	return cov, nil
+}
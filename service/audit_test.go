@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sec-data-pipeline/extractor/external/edgartest"
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+func TestAudit(t *testing.T) {
+	var tests = []struct {
+		name      string
+		runFaults map[string]edgartest.Fault
+		stored    []string
+		faults    map[string]edgartest.Fault
+		want      []string
+		failed    []string
+	}{
+		{
+			"Reports full coverage",
+			nil,
+			nil,
+			nil,
+			[]string{"0000320193 100.00 1/1", "0000789019 100.00 1/1"},
+			nil,
+		},
+		{
+			"Reports missing filings",
+			map[string]edgartest.Fault{"/submissions/CIK0000789019": {Status: 404, Times: 1}},
+			nil,
+			nil,
+			[]string{"0000320193 100.00 1/1", "0000789019 0.00 0/1 missing 000095017023054855"},
+			nil,
+		},
+		{
+			"Flags disappeared filings",
+			nil,
+			[]string{"000032019322000001"},
+			nil,
+			[]string{"0000320193 100.00 1/1 disappeared 000032019322000001", "0000789019 100.00 1/1"},
+			nil,
+		},
+		{
+			"Skips companies without submissions",
+			nil,
+			nil,
+			map[string]edgartest.Fault{"/submissions/CIK0000789019": {Status: 404}},
+			[]string{"0000320193 100.00 1/1"},
+			[]string{"0000789019"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			for prefix, fault := range test.runFaults {
+				server.AddFault(prefix, fault)
+			}
+			run := newTestRun(t, server, &Options{})
+			if err := run.s.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range test.stored {
+				filID, err := run.db.BeginFiling(context.Background(), &storage.FilingRecord{CompanyID: 1, SecID: id})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = run.db.CommitFiling(context.Background(), filID, &storage.DocumentRecord{Name: "old.htm"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for prefix, fault := range test.faults {
+				server.AddFault(prefix, fault)
+			}
+			report, err := run.s.Audit(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, cov := range report.Companies {
+				line := fmt.Sprintf("%s %.2f %d/%d", cov.CIK, cov.Coverage, cov.Stored, cov.Expected)
+				for _, id := range cov.Missing {
+					line += " missing " + id
+				}
+				for _, id := range cov.Disappeared {
+					line += " disappeared " + id
+				}
+				got = append(got, line)
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if strings.Join(report.Failed, ",") != strings.Join(test.failed, ",") {
+				t.Errorf("got failed companies %v, want %v", report.Failed, test.failed)
+			}
+			if len(run.db.Coverage()) != len(report.Companies) {
+				t.Errorf("got %d coverage records, want %d", len(run.db.Coverage()), len(report.Companies))
+			}
+			for _, fil := range run.db.Filings() {
+				disappeared := slices.Contains(test.stored, fil.SecID)
+				if fil.DisappearedAt.Valid != disappeared {
+					t.Errorf("got filing %s flagged %v, want %v", fil.SecID, fil.DisappearedAt.Valid, disappeared)
+				}
+			}
+		})
+	}
+}
@@ -1,15 +1,59 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/sec-data-pipeline/extractor/external"
 	"github.com/sec-data-pipeline/extractor/storage"
 )
 
+const (
+	SourceIndex      = "index"
+	SourceSubmission = "submission"
+
+	DiscoveryCompanies = "companies"
+	DiscoveryIndex     = "index"
+)
+
+type Options struct {
+	Policies  *external.PolicyConfig
+	Backfill  bool
+	Documents *external.DocumentFilter
+	Source    string
+	Headers   bool
+
+	XBRLDocuments *external.DocumentFilter
+
+	Discovery string
+	IndexFrom time.Time
+	IndexTo   time.Time
+	Universe  bool
+
+	WatchInterval time.Duration
+	WatchPages    int
+
+	// Time filings in flight get to finish once a run is asked to stop.
+	GracePeriod time.Duration
+
+	// Companies processed and files downloaded at the same time, Downloads
+	// defaults to Workers. Every company is handled by a single worker so
+	// its filings are stored in order.
+	Workers   int
+	Downloads int
+}
+
 type Extractor struct {
-	api     *external.API
-	db      storage.Database
-	archive storage.FileStorage
-	logger  storage.Logger
+	api       *external.API
+	db        storage.Database
+	archive   storage.FileStorage
+	logger    storage.Logger
+	opts      *Options
+	downloads chan struct{}
+	shutdown  *shutdown
 }
 
 func NewExtractorService(
@@ -17,67 +61,239 @@ func NewExtractorService(
 	db storage.Database,
 	archive storage.FileStorage,
 	logger storage.Logger,
+	opts *Options,
 ) *Extractor {
-	return &Extractor{api: api, db: db, archive: archive, logger: logger}
+	downloads := opts.Downloads
+	if downloads < 1 {
+		downloads = max(1, opts.Workers)
+	}
+	return &Extractor{
+		api:       api,
+		db:        db,
+		archive:   archive,
+		logger:    logger,
+		opts:      opts,
+		downloads: make(chan struct{}, downloads),
+	}
 }
 
-func (s *Extractor) Run() error {
-	companies, err := s.db.GetCompanies()
+// Run extracts the new filings of every tracked company. Once ctx is done no
+// further companies or filings are started, the filings in flight get the
+// grace period to finish before they are cancelled, and the work left undone
+// is reported.
+func (s *Extractor) Run(ctx context.Context) error {
+	ctx, cancel, s := s.stoppable(ctx)
+	defer cancel()
+	indexed, err := s.discoverFilings(ctx)
 	if err != nil {
 		return err
 	}
-	for _, cmp := range companies {
-		filIDs, err := s.db.GetFilingIDs(cmp.ID)
+	companies, err := s.db.GetCompanies(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.forEach(len(companies), func(w *Extractor, i int) error {
+		cmp := companies[i]
+		if w.shutdown.stopping() {
+			w.shutdown.skipCompany(cmp.CIK)
+			return nil
+		}
+		filIDs, err := w.db.GetFilingIDs(ctx, cmp.ID)
 		if err != nil {
+			if ctx.Err() != nil {
+				w.shutdown.skipCompany(cmp.CIK)
+				return nil
+			}
 			return err
 		}
-		filings, err := s.getMissingFilings(cmp.CIK, filIDs)
-		if err != nil {
-			s.logger.Log(err.Error())
+		if indexed != nil && !hasNewFilings(indexed, cmp.CIK, filIDs) {
+			return nil
+		}
+		_, err = w.withLogPrefix("["+cmp.CIK+"] ").processCompany(ctx, cmp.ID, cmp.CIK, filIDs)
+		return err
+	})
+	if err != nil {
+		return errors.Join(err, s.shutdown.report(s.logger))
+	}
+	stats := s.api.LimiterStats()
+	s.logger.Log(fmt.Sprintf(
+		"Sent %d requests, waited %s on the rate limiter for %d of them",
+		stats.Requests,
+		stats.Waited,
+		stats.Waits,
+	))
+	return s.shutdown.report(s.logger)
+}
+
+// processCompany extracts the filings of a company which are not stored yet.
+// It returns the submissions read, or nil when they could not be read, and
+// only errors which have to abort the run.
+func (s *Extractor) processCompany(ctx context.Context, cmpID int, cik string, got []string) (*external.Submissions, error) {
+	sub, err := s.api.GetSubmissions(ctx, cik, s.opts.Policies.For(cik), s.opts.Backfill)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.shutdown.skipCompany(cik)
+			return nil, nil
+		}
+		return nil, s.handleAPIError(cik, err)
+	}
+	if err := s.syncCompany(ctx, cmpID, cik, sub.Company); err != nil {
+		return nil, err
+	}
+	if err := s.quarantine(ctx, cmpID, cik, sub.Rejected); err != nil {
+		return nil, err
+	}
+	for _, fil := range s.getMissingFilings(cik, sub, got) {
+		if s.shutdown.stopping() {
+			s.shutdown.skipFiling(cik, fil.GetID(), false)
 			continue
 		}
-		for _, fil := range filings {
-			mainFile, err := s.api.GetMainFile(cmp.CIK, fil)
-			if err != nil {
-				s.logger.Log(err.Error())
-				continue
-			}
-			ex, err := mainFile.GetExtension()
-			if err != nil {
-				s.logger.Log(err.Error())
-				continue
-			}
-			err = s.db.InsertFiling(
-				cmp.ID,
-				fil.GetID(),
-				fil.Form,
-				mainFile.Name,
-				fil.FilingDate,
-				fil.ReportDate,
-				fil.AcceptDate,
-				mainFile.LastModified,
-			)
-			if err != nil {
-				s.logger.Log(err.Error())
+		if err := s.processFiling(ctx, cmpID, cik, fil); err != nil {
+			if ctx.Err() != nil {
+				s.shutdown.skipFiling(cik, fil.GetID(), true)
 				continue
 			}
-			err = s.archive.PutObject(fil.GetID()+ex, mainFile.Content)
-			if err != nil {
-				s.logger.Log(err.Error())
+			if err := s.handleAPIError(cik, err); err != nil {
+				return nil, err
 			}
 		}
 	}
+	return sub, nil
+}
+
+// handleAPIError logs errors which only affect a single company or filing and
+// returns the error when the whole run has to be aborted. Being rate limited
+// or served block pages after all retries means EDGAR is blocking us, so
+// continuing would only extend the block.
+func (s *Extractor) handleAPIError(cik string, err error) error {
+	switch {
+	case errors.Is(err, external.ErrRateLimited), errors.Is(err, external.ErrBlockPage):
+		return fmt.Errorf("Aborting run at company '%s', %w", cik, err)
+	case errors.Is(err, external.ErrInvalidContent):
+		s.logger.Log(fmt.Sprintf("Rejected invalid content for company '%s', %s", cik, err.Error()))
+	case errors.Is(err, external.ErrServerError):
+		s.logger.Log(fmt.Sprintf("Retrying next run for company '%s', %s", cik, err.Error()))
+	default:
+		s.logger.Log(fmt.Sprintf("Skipping for company '%s', %s", cik, err.Error()))
+	}
 	return nil
 }
 
-func (s *Extractor) getMissingFilings(cik string, got []string) ([]*external.Filing, error) {
-	filings, err := s.api.GetFilings(cik)
+// discoverFilings reads the EDGAR indexes and returns the IDs of the filings
+// listed for every CIK, so only companies with new filings get their
+// submissions requested. In universe mode every filer found which is not
+// known yet is tracked, removed companies stay removed.
+func (s *Extractor) discoverFilings(ctx context.Context) (map[string][]string, error) {
+	if s.opts.Discovery != DiscoveryIndex {
+		return nil, nil
+	}
+	entries, err := s.api.GetIndex(ctx, s.opts.IndexFrom, s.opts.IndexTo, s.opts.Policies)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Could not read EDGAR index, %w", err)
+	}
+	indexed := make(map[string][]string)
+	names := make(map[string]string)
+	for _, e := range entries {
+		indexed[e.CIK] = append(indexed[e.CIK], e.GetID())
+		names[e.CIK] = e.CompanyName
+	}
+	s.logger.Log(fmt.Sprintf(
+		"Found %d filings of %d companies in the EDGAR index from %s to %s",
+		len(entries),
+		len(indexed),
+		s.opts.IndexFrom.Format("2006-01-02"),
+		s.opts.IndexTo.Format("2006-01-02"),
+	))
+	if s.opts.Universe {
+		for cik, name := range names {
+			if _, err := s.db.DiscoverCompany(ctx, cik, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return indexed, nil
+}
+
+// hasNewFilings reports whether the index lists a filing of the company which
+// is not stored yet.
+func hasNewFilings(indexed map[string][]string, cik string, got []string) bool {
+	cik, err := external.NormalizeCIK(cik)
+	if err != nil {
+		return false
+	}
+	for _, id := range indexed[cik] {
+		if !slices.Contains(got, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Extractor) syncCompany(ctx context.Context, cmpID int, cik string, cmp *external.Company) error {
+	rec := &storage.CompanyRecord{
+		Name:                 cmp.Name,
+		EntityType:           cmp.EntityType,
+		SIC:                  cmp.SIC,
+		SICDescription:       cmp.SICDescription,
+		Tickers:              cmp.Tickers,
+		Exchanges:            cmp.Exchanges,
+		EIN:                  cmp.EIN,
+		Category:             cmp.Category,
+		FiscalYearEnd:        cmp.FiscalYearEnd,
+		StateOfIncorporation: cmp.StateOfIncorporation,
+		Phone:                cmp.Phone,
+		BusinessAddress:      transformAddress(cmp.BusinessAddress),
+		MailAddress:          transformAddress(cmp.MailAddress),
+	}
+	for _, v := range cmp.FormerNames {
+		rec.FormerNames = append(rec.FormerNames, &storage.FormerNameRecord{Name: v.Name, From: v.From, To: v.To})
+	}
+	changed, err := s.db.UpdateCompany(ctx, cmpID, rec)
+	if err != nil {
+		return err
+	}
+	if changed {
+		s.logger.Log(fmt.Sprintf("Updated profile of company '%s' (%s)", cik, cmp.Name))
+	}
+	return nil
+}
+
+// quarantine records the rows of the submissions which failed validation, so
+// they can be inspected without holding up the valid filings.
+func (s *Extractor) quarantine(ctx context.Context, cmpID int, cik string, rejected []*external.Rejected) error {
+	for _, rj := range rejected {
+		inserted, err := s.db.QuarantineFiling(ctx, &storage.QuarantineRecord{
+			CompanyID: cmpID,
+			SecID:     rj.SecID,
+			Form:      rj.Form,
+			Reason:    rj.Reason,
+			Row:       rj.Row,
+		})
+		if err != nil {
+			return err
+		}
+		// rows quarantined before are rejected again by every run
+		if inserted {
+			s.logger.Log(fmt.Sprintf(
+				"Quarantined filing '%s' (%s) of company '%s', %s",
+				rj.SecID,
+				rj.Form,
+				cik,
+				rj.Reason,
+			))
+		}
+	}
+	return nil
+}
+
+func (s *Extractor) getMissingFilings(cik string, sub *external.Submissions, got []string) []*external.Filing {
+	// every run and poll skips the same filings, so only their number is logged
+	if len(sub.Skipped) > 0 {
+		s.logger.Log(fmt.Sprintf("Skipped %d filings of company '%s' by policy", len(sub.Skipped), cik))
 	}
 	var missing []*external.Filing
 outer:
-	for _, fil := range filings {
+	for _, fil := range sub.Filings {
 		for _, id := range got {
 			if fil.GetID() == id {
 				continue outer
@@ -85,5 +301,5 @@ outer:
 		}
 		missing = append(missing, fil)
 	}
-	return missing, nil
+	return missing
 }
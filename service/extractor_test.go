@@ -1,7 +1,524 @@
 package service
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/external"
+	"github.com/sec-data-pipeline/extractor/external/edgartest"
+	"github.com/sec-data-pipeline/extractor/storage"
+	"github.com/sec-data-pipeline/extractor/storage/storagetest"
+)
+
+var testDocument = []byte(`<html>
+<head><title>10-K</title></head>
+<body>
+<p>UNITED STATES SECURITIES AND EXCHANGE COMMISSION</p>
+<p>Washington, D.C. 20549</p>
+<p>FORM 10-K</p>
+<p>ANNUAL REPORT PURSUANT TO SECTION 13 OR 15(d) OF THE SECURITIES EXCHANGE ACT OF 1934</p>
+<p>For the fiscal year ended September 30, 2023</p>
+</body>
+</html>
+`)
+
+type testLogger struct {
+	msgs []string
+}
+
+func (l *testLogger) Log(msg string) {
+	l.msgs = append(l.msgs, msg)
+}
+
+func newTestEDGAR() *edgartest.Server {
+	server := edgartest.NewServer()
+	server.AddCompany(&edgartest.Company{
+		CIK:  "0000320193",
+		Name: "Apple Inc.",
+		Filings: []*edgartest.Filing{
+			{
+				AccessionNumber:    "0000320193-23-000106",
+				Form:               "10-K",
+				FilingDate:         "2023-11-03",
+				ReportDate:         "2023-09-30",
+				AcceptanceDateTime: "2023-11-02T18:08:27.000Z",
+				PrimaryDocument:    "aapl-20230930.htm",
+				Documents: map[string][]byte{
+					"aapl-20230930.htm": testDocument,
+					"ex21.htm":          testDocument,
+				},
+			},
+			{
+				AccessionNumber:    "0000320193-23-000104",
+				Form:               "8-K",
+				FilingDate:         "2023-11-02",
+				AcceptanceDateTime: "2023-11-02T16:30:24.000Z",
+				PrimaryDocument:    "aapl-20231102.htm",
+				Documents:          map[string][]byte{"aapl-20231102.htm": testDocument},
+			},
+		},
+	})
+	server.AddCompany(&edgartest.Company{
+		CIK:  "0000789019",
+		Name: "MICROSOFT CORP",
+		Filings: []*edgartest.Filing{
+			{
+				AccessionNumber:    "0000950170-23-054855",
+				Form:               "10-Q",
+				FilingDate:         "2023-10-24",
+				ReportDate:         "2023-09-30",
+				AcceptanceDateTime: "2023-10-24T16:05:30.000Z",
+				PrimaryDocument:    "msft-20230930.htm",
+				Documents:          map[string][]byte{"msft-20230930.htm": testDocument},
+			},
+		},
+	})
+	return server
+}
+
+type testRun struct {
+	server *edgartest.Server
+	db     interface {
+		storage.Database
+		Filings() []storage.FilingRecord
+		Documents() []storage.DocumentRecord
+		Quarantined() []storage.QuarantineRecord
+		Coverage() []storage.CoverageRecord
+	}
+	dest   string
+	logger *testLogger
+	s      *Extractor
+}
+
+func newTestRun(t *testing.T, server *edgartest.Server, opts *Options) *testRun {
+	cfg := &external.Config{
+		Name:           "Example Corp",
+		Email:          "data@example.com",
+		Timeout:        5 * time.Second,
+		RetryAttempts:  3,
+		RetryBaseDelay: time.Millisecond,
+	}
+	server.Configure(cfg)
+	api, err := external.NewAPI(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := storagetest.NewMemoryDB()
+	db.AddCompany(context.Background(), "0000320193", "")
+	db.AddCompany(context.Background(), "0000789019", "")
+	if opts.Policies == nil {
+		opts.Policies = external.DefaultPolicyConfig()
+	}
+	run := &testRun{server: server, db: db, dest: t.TempDir(), logger: &testLogger{}}
+	run.s = NewExtractorService(api, db, storage.NewFolder(run.dest), run.logger, opts)
+	return run
+}
+
+func (r *testRun) filingIDs() []string {
+	var ids []string
+	for _, fil := range r.db.Filings() {
+		if fil.State == storage.FilingCommitted {
+			ids = append(ids, fil.SecID)
+		}
+	}
+	return ids
+}
 
 func TestRun(t *testing.T) {
+	var tests = []struct {
+		name   string
+		faults map[string]edgartest.Fault
+		err    error
+		want   []string
+	}{
+		{"Extracts filings allowed by policy", nil, nil, []string{"000032019323000106", "000095017023054855"}},
+		{
+			"Skips company without submissions",
+			map[string]edgartest.Fault{"/submissions/CIK0000789019": {Status: 404}},
+			nil,
+			[]string{"000032019323000106"},
+		},
+		{
+			"Skips company with malformed submissions",
+			map[string]edgartest.Fault{"/submissions/CIK0000320193": {Malformed: true}},
+			nil,
+			[]string{"000095017023054855"},
+		},
+		{
+			"Retries rate limited requests",
+			map[string]edgartest.Fault{"/submissions/": {Status: 429, Times: 2}},
+			nil,
+			[]string{"000032019323000106", "000095017023054855"},
+		},
+		{
+			"Retries server errors of documents",
+			map[string]edgartest.Fault{"/Archives/edgar/data/0000320193/000032019323000106/aapl": {Status: 503, Times: 2}},
+			nil,
+			[]string{"000032019323000106", "000095017023054855"},
+		},
+		{
+			"Aborts when rate limited after all retries",
+			map[string]edgartest.Fault{"/submissions/": {Status: 429}},
+			external.ErrRateLimited,
+			nil,
+		},
+		{
+			"Aborts on block pages",
+			map[string]edgartest.Fault{"/Archives/": {BlockPage: true}},
+			external.ErrBlockPage,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			for prefix, fault := range test.faults {
+				server.AddFault(prefix, fault)
+			}
+			run := newTestRun(t, server, &Options{})
+			err := run.s.Run(context.Background())
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("got error %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := run.filingIDs()
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got filings %v, want %v", got, test.want)
+			}
+			for _, id := range got {
+				if _, err := os.Stat(filepath.Join(run.dest, id+".htm")); err != nil {
+					t.Errorf("main document of %s not archived, %s", id, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestRunIsIncremental(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	run := newTestRun(t, server, &Options{})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	archived := server.Requests("/Archives/")
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Requests("/Archives/"); n != archived {
+		t.Errorf("second run sent %d requests for stored filings", n-archived)
+	}
+	server.AddFiling("0000789019", &edgartest.Filing{
+		AccessionNumber:    "0000950170-24-008814",
+		Form:               "10-Q",
+		FilingDate:         "2024-01-30",
+		ReportDate:         "2023-12-31",
+		AcceptanceDateTime: "2024-01-30T16:06:07.000Z",
+		PrimaryDocument:    "msft-20231231.htm",
+		Documents:          map[string][]byte{"msft-20231231.htm": testDocument},
+	})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := run.filingIDs(); len(got) != 3 || got[2] != "000095017024008814" {
+		t.Errorf("got filings %v, want the new 10-Q last", got)
+	}
+}
+
+func TestRunResumesFailedFilings(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	server.AddFault("/Archives/edgar/data/0000320193/000032019323000106/aapl", edgartest.Fault{Status: 404, Times: 1})
+	run := newTestRun(t, server, &Options{})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var failed *storage.FilingRecord
+	filings := run.db.Filings()
+	for i := range filings {
+		if filings[i].SecID == "000032019323000106" {
+			failed = &filings[i]
+		}
+	}
+	if failed == nil || failed.State != storage.FilingFailed || len(failed.StateError) < 1 {
+		t.Fatalf("got filing %+v, want it recorded as failed with the error", failed)
+	}
+	if got := run.filingIDs(); strings.Join(got, ",") != "000095017023054855" {
+		t.Errorf("got committed filings %v, want the failed one left out", got)
+	}
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	filings = run.db.Filings()
+	if len(filings) != 2 || filings[0].State != storage.FilingCommitted || filings[0].Attempts != 2 {
+		t.Errorf("got filings %+v, want the failed filing committed by its second attempt", filings)
+	}
+}
+
+func TestRunDocumentErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		fault edgartest.Fault
+		state string
+		error string
+	}{
+		{"Notes documents EDGAR does not serve", edgartest.Fault{Status: 404}, storage.FilingCommitted, "ex21.htm"},
+		{"Fails filing when a document fails", edgartest.Fault{Status: 503}, storage.FilingFailed, "ex21.htm"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			server.AddFault("/Archives/edgar/data/0000320193/000032019323000106/ex21.htm", test.fault)
+			run := newTestRun(t, server, &Options{Documents: &external.DocumentFilter{All: true}})
+			if err := run.s.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			fil := run.db.Filings()[0]
+			if fil.SecID != "000032019323000106" || fil.State != test.state || !strings.Contains(fil.StateError, test.error) {
+				t.Errorf("got filing %s %s '%s', want %s with '%s'", fil.SecID, fil.State, fil.StateError, test.state, test.error)
+			}
+		})
+	}
+}
+
+// panickingArchive panics while storing objects whose key ends with suffix.
+type panickingArchive struct {
+	storage.FileStorage
+	suffix string
+}
+
+func (a *panickingArchive) PutStream(ctx context.Context, key string, r io.Reader, size int64) error {
+	if strings.HasSuffix(key, a.suffix) {
+		panic("archive broken")
+	}
+	return a.FileStorage.PutStream(ctx, key, r, size)
+}
+
+func TestRunLogsSkippedCount(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	run := newTestRun(t, server, &Options{})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log := strings.Join(run.logger.msgs, "\n")
+	if !strings.Contains(log, "Skipped 1 filings of company '0000320193' by policy") || strings.Contains(log, "0000320193-23-000104") {
+		t.Errorf("got log %v, want only the number of filings skipped by policy", run.logger.msgs)
+	}
+}
+
+func TestRunDocumentPanic(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	run := newTestRun(t, server, &Options{Documents: &external.DocumentFilter{All: true}})
+	run.s.archive = &panickingArchive{FileStorage: run.s.archive, suffix: "ex21.htm"}
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fil := run.db.Filings()[0]
+	if fil.State != storage.FilingFailed || !strings.Contains(fil.StateError, "archive broken") {
+		t.Errorf("got filing %s %s '%s', want it failed by the panic", fil.SecID, fil.State, fil.StateError)
+	}
+	if strings.Contains(fil.StateError, "goroutine") {
+		t.Errorf("got stack in state error '%s', want it only logged", fil.StateError)
+	}
+	if !strings.Contains(strings.Join(run.logger.msgs, "\n"), "goroutine") {
+		t.Errorf("got log %v, want the stack of the panic", run.logger.msgs)
+	}
+}
+
+func TestDownloadStopsWaiting(t *testing.T) {
+	s := &Extractor{downloads: make(chan struct{}, 1)}
+	s.downloads <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.download(ctx, func() error {
+		t.Errorf("download ran without a free slot")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	for _, id := range []string{"000094", "000077", "000064"} {
+		server.AddFiling("0000320193", &edgartest.Filing{
+			AccessionNumber:    "0000320193-23-" + id,
+			Form:               "10-Q",
+			FilingDate:         "2023-08-04",
+			ReportDate:         "2023-07-01",
+			AcceptanceDateTime: "2023-08-03T18:04:43.000Z",
+			PrimaryDocument:    "aapl-" + id + ".htm",
+			Documents: map[string][]byte{
+				"aapl-" + id + ".htm": testDocument,
+				"ex31.htm":            testDocument,
+				"ex32.htm":            testDocument,
+			},
+		})
+	}
+	server.SetLatency(5 * time.Millisecond)
+	documents, _ := external.ParseDocumentFilter("all")
+	run := newTestRun(t, server, &Options{Workers: 2, Downloads: 3, Documents: documents})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var apple []string
+	for _, id := range run.filingIDs() {
+		if strings.HasPrefix(id, "0000320193") {
+			apple = append(apple, id)
+		}
+	}
+	want := "000032019323000064,000032019323000077,000032019323000094,000032019323000106"
+	if strings.Join(apple, ",") != want {
+		t.Errorf("got filings %v of Apple, want them in submission order", apple)
+	}
+	if n := len(run.db.Filings()); n != 5 {
+		t.Errorf("got %d filings, want 5", n)
+	}
+	var names []string
+	for _, doc := range run.db.Documents() {
+		if strings.HasPrefix(doc.StorageKey, "000032019323000094") {
+			names = append(names, doc.Name)
+		}
+	}
+	if strings.Join(names, ",") != "aapl-000094.htm,ex31.htm,ex32.htm" {
+		t.Errorf("got documents %v, want them in the order of the index", names)
+	}
+	for _, msg := range run.logger.msgs {
+		if strings.HasPrefix(msg, "[worker ") && !strings.Contains(msg, "] [0000") {
+			t.Errorf("log line %q does not name the company", msg)
+		}
+	}
+}
+
+// stoppingStorage asks the run to stop when the first object is stored and
+// then holds the upload until the work context is done, if hold is set.
+type stoppingStorage struct {
+	storage.FileStorage
+	stop context.CancelFunc
+	hold bool
+}
+
+func (f *stoppingStorage) PutStream(ctx context.Context, key string, r io.Reader, size int64) error {
+	f.stop()
+	if f.hold {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.FileStorage.PutStream(ctx, key, r, size)
+}
+
+func TestRunStops(t *testing.T) {
+	var tests = []struct {
+		name  string
+		grace time.Duration
+		hold  bool
+		want  []string
+		log   string
+	}{
+		{"Finishes filings in flight", time.Minute, false, []string{"000032019323000106"}, "Did not process 1 companies: 0000789019"},
+		{"Cancels filings after the grace period", 0, true, nil, "Cancelled 1 filings after the grace period, the next run resumes them: 0000320193/000032019323000106"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			run := newTestRun(t, server, &Options{GracePeriod: test.grace})
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			run.s.archive = &stoppingStorage{FileStorage: run.s.archive, stop: stop, hold: test.hold}
+			err := run.s.Run(ctx)
+			if !errors.Is(err, ErrInterrupted) {
+				t.Fatalf("got error %v, want %v", err, ErrInterrupted)
+			}
+			if got := run.filingIDs(); strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got filings %v, want %v", got, test.want)
+			}
+			if !strings.Contains(strings.Join(run.logger.msgs, "\n"), test.log) {
+				t.Errorf("got log %v, want a line containing %q", run.logger.msgs, test.log)
+			}
+		})
+	}
+}
+
+func TestRunQuarantinesInvalidRows(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	server.AddFiling("0000320193", &edgartest.Filing{
+		AccessionNumber:    "0000320193-24-000006",
+		Form:               "10-Q",
+		FilingDate:         "2024-02-30",
+		ReportDate:         "2023-12-30",
+		AcceptanceDateTime: "2024-02-01T18:03:37.000Z",
+		PrimaryDocument:    "aapl-20231230.htm",
+		Documents:          map[string][]byte{"aapl-20231230.htm": testDocument},
+	})
+	run := newTestRun(t, server, &Options{})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := run.filingIDs(); strings.Join(got, ",") != "000032019323000106,000095017023054855" {
+		t.Errorf("got filings %v, want the valid ones only", got)
+	}
+	rejected := run.db.Quarantined()
+	if len(rejected) != 1 || rejected[0].SecID != "0000320193-24-000006" {
+		t.Fatalf("got quarantined rows %+v, want the 10-Q with the invalid filing date", rejected)
+	}
+	if !strings.Contains(rejected[0].Reason, "filing date") || !strings.Contains(rejected[0].Row, "2024-02-30") {
+		t.Errorf("got reason %s and row %s, want the filing date and the raw row", rejected[0].Reason, rejected[0].Row)
+	}
+	run.logger.msgs = nil
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range run.logger.msgs {
+		if strings.HasPrefix(msg, "Quarantined") {
+			t.Errorf("got '%s' again by the second run", msg)
+		}
+	}
+}
 
+func TestRunSubmissionSource(t *testing.T) {
+	server := newTestEDGAR()
+	defer server.Close()
+	documents, _ := external.ParseDocumentFilter("all")
+	run := newTestRun(t, server, &Options{Source: SourceSubmission, Documents: documents, Headers: true})
+	if err := run.s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.json", "0000320193-23-000106.hdr.sgml"} {
+		if n := server.Requests("/Archives/edgar/data/0000320193/000032019323000106/" + name); n != 0 {
+			t.Errorf("got %d requests of %s, want the submission text file only", n, name)
+		}
+	}
+	if got := run.filingIDs(); len(got) != 2 {
+		t.Errorf("got filings %v, want both committed with their headers", got)
+	}
+	var names []string
+	for _, doc := range run.db.Documents() {
+		names = append(names, doc.Name)
+	}
+	if strings.Join(names, ",") != "aapl-20230930.htm,ex21.htm,msft-20230930.htm" {
+		t.Errorf("got documents %v, want both main documents and the exhibit", names)
+	}
+	data, err := os.ReadFile(filepath.Join(run.dest, "000032019323000106.htm"))
+	if err != nil || string(data) != string(testDocument) {
+		t.Errorf("main document not archived from the submission, %v", err)
+	}
 }
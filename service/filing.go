@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/sec-data-pipeline/extractor/external"
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+// processFiling moves a filing through its lifecycle: it is recorded as
+// discovered, its documents are archived while downloading and only once
+// every object is archived the documents are recorded and the filing is
+// committed. A filing which fails on the way is marked as failed, every
+// filing which is not committed is extracted again by the next run.
+func (s *Extractor) processFiling(ctx context.Context, cmpID int, cik string, fil *external.Filing) error {
+	filID, err := s.db.BeginFiling(ctx, &storage.FilingRecord{
+		CompanyID:    cmpID,
+		SecID:        fil.GetID(),
+		Form:         fil.Form,
+		OriginalFile: fil.GetMainFileName(),
+		FilingDate:   fil.FilingDate,
+		ReportDate:   fil.ReportDate,
+		AcceptDate:   fil.AcceptDate,
+
+		PrimaryDocDescription: fil.PrimaryDocDescription,
+		Act:                   fil.Act,
+		FileNumber:            fil.FileNumber,
+		FilmNumber:            fil.FilmNumber,
+		Items:                 fil.Items,
+		SubmissionSize:        fil.Size,
+		IsXBRL:                fil.IsXBRL,
+		IsInlineXBRL:          fil.IsInlineXBRL,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.extractFiling(ctx, filID, cik, fil); err != nil {
+		// the state is recorded even if ctx is done, so the next run
+		// knows the filing has to be resumed
+		failErr := s.db.SetFilingState(context.WithoutCancel(ctx), filID, storage.FilingFailed, err.Error())
+		return errors.Join(err, failErr)
+	}
+	return nil
+}
+
+func (s *Extractor) extractFiling(ctx context.Context, filID int, cik string, fil *external.Filing) error {
+	if err := s.db.SetFilingState(ctx, filID, storage.FilingDownloading, ""); err != nil {
+		return err
+	}
+	archive := s.archiveFiles
+	if s.opts.Source == SourceSubmission {
+		archive = s.archiveSubmission
+	}
+	arch, err := archive(ctx, cik, fil)
+	if err != nil {
+		return err
+	}
+	hdr, err := s.getHeader(ctx, cik, fil, arch.header)
+	if err != nil {
+		return err
+	}
+	if err := s.db.SetFilingState(ctx, filID, storage.FilingArchived, ""); err != nil {
+		return err
+	}
+	return s.commitFiling(ctx, filID, arch, hdr)
+}
+
+// archived are the documents of a filing written to the archive.
+type archived struct {
+	mainDoc *storage.DocumentRecord
+	docs    []*storage.DocumentRecord
+	// documents listed in the filing index which EDGAR does not serve
+	notFound []string
+	// header read along with the documents, if any
+	header *external.Header
+}
+
+// archiveFiles archives the primary document and, depending on the document
+// filter, further files of the filing. The primary document keeps its
+// original key, every other document is stored under the accession's key
+// prefix. Any document which fails fails the filing, except for documents
+// EDGAR does not serve although the index lists them.
+func (s *Extractor) archiveFiles(ctx context.Context, cik string, fil *external.Filing) (*archived, error) {
+	files, err := s.api.GetFiles(ctx, cik, fil)
+	if err != nil {
+		return nil, err
+	}
+	mainIdx := -1
+	for i, f := range files {
+		if f.Name == fil.GetMainFileName() {
+			mainIdx = i
+		}
+	}
+	if mainIdx < 0 {
+		return nil, fmt.Errorf(
+			"%w, main file '%s' of filing '%s' not in index",
+			external.ErrNotFound,
+			fil.GetMainFileName(),
+			fil.GetID(),
+		)
+	}
+	mainFile := files[mainIdx]
+	ex, err := mainFile.GetExtension()
+	if err != nil {
+		return nil, err
+	}
+	mainKey := fil.GetID() + ex
+	size, hash, err := s.archiveFile(ctx, cik, fil, mainFile.Name, mainFile.Size, mainKey)
+	if err != nil {
+		return nil, err
+	}
+	docs := []*storage.DocumentRecord{{
+		Name:         mainFile.Name,
+		StorageKey:   mainKey,
+		Size:         size,
+		SHA256:       hash,
+		LastModified: mainFile.LastModified,
+	}}
+	// further documents are downloaded concurrently, limited by the download
+	// slots, but recorded in the order of the index
+	extra := make([]*storage.DocumentRecord, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		if i == mainIdx || !s.wantsDocument(fil, f.Name) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string, sizeHint int64, lastModified sql.NullTime) {
+			defer wg.Done()
+			// a panic fails the filing instead of crashing the process
+			defer func() {
+				if r := recover(); r != nil {
+					s.logger.Log(fmt.Sprintf("Panic while archiving %s of filing '%s', %v\n%s", name, fil.GetID(), r, debug.Stack()))
+					errs[i] = fmt.Errorf("Panic while archiving %s of filing '%s', %v", name, fil.GetID(), r)
+				}
+			}()
+			key := fil.GetID() + "/" + name
+			docSize, docHash, err := s.archiveFile(ctx, cik, fil, name, sizeHint, key)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			extra[i] = &storage.DocumentRecord{
+				Name:         name,
+				StorageKey:   key,
+				Size:         docSize,
+				SHA256:       docHash,
+				LastModified: lastModified,
+			}
+		}(i, f.Name, f.Size, f.LastModified)
+	}
+	wg.Wait()
+	arch := &archived{mainDoc: docs[0]}
+	for i, err := range errs {
+		switch {
+		case errors.Is(err, external.ErrNotFound):
+			s.logger.Log(fmt.Sprintf("Skipping document of filing '%s', %s", fil.GetID(), err.Error()))
+			arch.notFound = append(arch.notFound, files[i].Name)
+		case err != nil:
+			return nil, err
+		case extra[i] != nil:
+			docs = append(docs, extra[i])
+		}
+	}
+	arch.docs = docs
+	return arch, nil
+}
+
+// archiveSubmission gets every document of the filing and its header from the
+// complete submission text file with a single request instead of one per file.
+func (s *Extractor) archiveSubmission(ctx context.Context, cik string, fil *external.Filing) (*archived, error) {
+	var mainDoc *storage.DocumentRecord
+	var docs []*storage.DocumentRecord
+	var header *external.Header
+	err := s.download(ctx, func() error {
+		var err error
+		header, err = s.api.ReadSubmission(ctx, cik, fil, func(doc *external.Document) error {
+			name := doc.FileName
+			if len(name) < 1 {
+				name = "document-" + doc.Sequence + ".txt"
+			}
+			isMain := name == fil.GetMainFileName()
+			if !isMain && !s.wantsDocument(fil, name) {
+				return nil
+			}
+			key := fil.GetID() + "/" + name
+			if isMain {
+				ex, err := doc.GetExtension()
+				if err != nil {
+					return err
+				}
+				key = fil.GetID() + ex
+			}
+			size, hash, err := s.store(ctx, key, bytes.NewReader(doc.Content), int64(len(doc.Content)))
+			if err != nil {
+				return fmt.Errorf("Could not archive %s of filing '%s', %w", name, fil.GetID(), err)
+			}
+			rec := &storage.DocumentRecord{Name: name, StorageKey: key, Size: size, SHA256: hash}
+			if isMain {
+				mainDoc = rec
+			}
+			docs = append(docs, rec)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mainDoc == nil {
+		return nil, fmt.Errorf(
+			"%w, main file '%s' of filing '%s' not in submission",
+			external.ErrNotFound,
+			fil.GetMainFileName(),
+			fil.GetID(),
+		)
+	}
+	return &archived{mainDoc: mainDoc, docs: docs, header: header}, nil
+}
+
+// wantsDocument routes XBRL filings into extra processing by archiving their
+// XBRL files on top of the documents selected for every filing.
+func (s *Extractor) wantsDocument(fil *external.Filing, name string) bool {
+	if s.opts.Documents.Match(name) {
+		return true
+	}
+	return fil.IsXBRL && s.opts.XBRLDocuments.Match(name)
+}
+
+// commitFiling records the documents and header of an archived filing and
+// commits it last, so a committed filing always has all of its objects.
+// Documents EDGAR does not serve are noted on the committed filing.
+func (s *Extractor) commitFiling(ctx context.Context, filID int, arch *archived, hdr *storage.HeaderRecord) error {
+	for _, doc := range arch.docs {
+		doc.FilingID = filID
+		if err := s.db.InsertDocument(ctx, doc); err != nil {
+			return err
+		}
+	}
+	if hdr != nil {
+		if err := s.db.InsertHeader(ctx, filID, hdr); err != nil {
+			return err
+		}
+	}
+	if err := s.db.CommitFiling(ctx, filID, arch.mainDoc); err != nil {
+		return err
+	}
+	if len(arch.notFound) < 1 {
+		return nil
+	}
+	reason := "Documents not found on EDGAR: " + strings.Join(arch.notFound, ", ")
+	return s.db.SetFilingState(ctx, filID, storage.FilingCommitted, reason)
+}
+
+// getHeader returns nil when header extraction is disabled. The header is
+// only requested if it was not read along with the documents.
+func (s *Extractor) getHeader(
+	ctx context.Context,
+	cik string,
+	fil *external.Filing,
+	hdr *external.Header,
+) (*storage.HeaderRecord, error) {
+	if !s.opts.Headers {
+		return nil, nil
+	}
+	if hdr == nil {
+		var err error
+		hdr, err = s.api.GetHeader(ctx, cik, fil)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get header of filing '%s', %w", fil.GetID(), err)
+		}
+	}
+	rec := &storage.HeaderRecord{PeriodOfReport: hdr.PeriodOfReport, Items: hdr.Items}
+	for _, p := range hdr.Parties {
+		rec.Parties = append(rec.Parties, &storage.PartyRecord{
+			Role:                 p.Role,
+			CIK:                  p.CIK,
+			Name:                 p.Name,
+			SIC:                  p.SIC,
+			IRSNumber:            p.IRSNumber,
+			StateOfIncorporation: p.StateOfIncorporation,
+			FiscalYearEnd:        p.FiscalYearEnd,
+			FilerStatus:          p.FilerStatus,
+			FormType:             p.FormType,
+			Act:                  p.Act,
+			FileNumber:           p.FileNumber,
+			FilmNumber:           p.FilmNumber,
+			BusinessAddress:      transformAddress(p.BusinessAddress),
+			MailAddress:          transformAddress(p.MailAddress),
+		})
+	}
+	return rec, nil
+}
+
+func transformAddress(addr *external.Address) storage.AddressRecord {
+	if addr == nil {
+		return storage.AddressRecord{}
+	}
+	return storage.AddressRecord{
+		Street1: addr.Street1,
+		Street2: addr.Street2,
+		City:    addr.City,
+		State:   addr.State,
+		Zip:     addr.Zip,
+		Phone:   addr.Phone,
+	}
+}
+
+// archiveFile streams a file of a filing from EDGAR into the archive and
+// returns the number of bytes written and their SHA-256 checksum.
+func (s *Extractor) archiveFile(
+	ctx context.Context,
+	cik string,
+	fil *external.Filing,
+	name string,
+	sizeHint int64,
+	key string,
+) (int64, string, error) {
+	var size int64
+	var hash string
+	err := s.download(ctx, func() error {
+		body, err := s.api.OpenFile(ctx, cik, fil, name)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		size, hash, err = s.store(ctx, key, body, sizeHint)
+		if err != nil {
+			return fmt.Errorf("Could not archive %s of filing '%s', %w", name, fil.GetID(), err)
+		}
+		return nil
+	})
+	return size, hash, err
+}
+
+func (s *Extractor) store(ctx context.Context, key string, r io.Reader, sizeHint int64) (int64, string, error) {
+	hash := sha256.New()
+	counter := &countingWriter{}
+	err := s.archive.PutStream(ctx, key, io.TeeReader(r, io.MultiWriter(hash, counter)), sizeHint)
+	if err != nil {
+		return 0, "", err
+	}
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+// forEach calls do for the numbers 0 to n-1 from Workers goroutines. Every
+// call gets its own copy of the service whose log lines name the worker. Once
+// a call returns an error no further calls are started and the errors of all
+// workers are returned together.
+func (s *Extractor) forEach(n int, do func(w *Extractor, i int) error) error {
+	workers := max(1, min(s.opts.Workers, n))
+	logger := &syncLogger{next: s.logger}
+	jobs := make(chan int)
+	aborted := make(chan struct{})
+	var abort sync.Once
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for id := 1; id <= workers; id++ {
+		w := *s
+		w.logger = logger
+		if workers > 1 {
+			w.logger = &prefixLogger{next: logger, prefix: fmt.Sprintf("[worker %d] ", id)}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case <-aborted:
+					continue
+				default:
+				}
+				if err := do(&w, i); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					abort.Do(func() { close(aborted) })
+				}
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-aborted:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// withLogPrefix returns a copy of the service whose log lines start with
+// prefix, when running concurrently.
+func (s *Extractor) withLogPrefix(prefix string) *Extractor {
+	if s.opts.Workers < 2 {
+		return s
+	}
+	w := *s
+	w.logger = &prefixLogger{next: s.logger, prefix: prefix}
+	return &w
+}
+
+// download holds one of the download slots shared by all workers while do
+// runs. It gives up waiting for a slot once ctx is done.
+func (s *Extractor) download(ctx context.Context, do func() error) error {
+	select {
+	case s.downloads <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.downloads }()
+	return do()
+}
+
+type syncLogger struct {
+	mu   sync.Mutex
+	next storage.Logger
+}
+
+func (l *syncLogger) Log(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next.Log(msg)
+}
+
+type prefixLogger struct {
+	next   storage.Logger
+	prefix string
+}
+
+func (l *prefixLogger) Log(msg string) {
+	l.next.Log(l.prefix + msg)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+var ErrInterrupted = errors.New("Interrupted")
+
+// shutdown tracks the work left undone once a run is asked to stop. From then
+// on no companies or filings are started, the filings in flight get the grace
+// period to finish.
+type shutdown struct {
+	stop context.Context
+
+	mu          sync.Mutex
+	companies   []string
+	filings     []string
+	interrupted []string
+}
+
+// withGrace returns a context for the work in flight which is only cancelled
+// grace after ctx is done.
+func withGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return work, func() {
+		stop()
+		cancel()
+	}
+}
+
+// stoppable returns the context for the work of a run and a copy of the
+// service which stops starting new work once ctx is done.
+func (s *Extractor) stoppable(ctx context.Context) (context.Context, context.CancelFunc, *Extractor) {
+	work, cancel := withGrace(ctx, s.opts.GracePeriod)
+	r := *s
+	r.shutdown = &shutdown{stop: ctx}
+	return work, cancel, &r
+}
+
+func (sd *shutdown) stopping() bool {
+	return sd != nil && sd.stop.Err() != nil
+}
+
+func (sd *shutdown) skipCompany(cik string) {
+	if sd == nil {
+		return
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.companies = append(sd.companies, cik)
+}
+
+func (sd *shutdown) skipFiling(cik string, id string, interrupted bool) {
+	if sd == nil {
+		return
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if interrupted {
+		sd.interrupted = append(sd.interrupted, cik+"/"+id)
+		return
+	}
+	sd.filings = append(sd.filings, cik+"/"+id)
+}
+
+// report logs what was left undone and returns ErrInterrupted if the run
+// stopped early.
+func (sd *shutdown) report(logger storage.Logger) error {
+	if !sd.stopping() {
+		return nil
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if len(sd.interrupted) > 0 {
+		logger.Log(fmt.Sprintf(
+			"Cancelled %d filings after the grace period, the next run resumes them: %s",
+			len(sd.interrupted),
+			strings.Join(sd.interrupted, ", "),
+		))
+	}
+	if len(sd.filings) > 0 {
+		logger.Log(fmt.Sprintf("Did not start %d filings: %s", len(sd.filings), strings.Join(sd.filings, ", ")))
+	}
+	if len(sd.companies) > 0 {
+		logger.Log(fmt.Sprintf("Did not process %d companies: %s", len(sd.companies), strings.Join(sd.companies, ", ")))
+	}
+	return fmt.Errorf(
+		"%w, %d companies and %d filings left undone",
+		ErrInterrupted,
+		len(sd.companies),
+		len(sd.filings)+len(sd.interrupted),
+	)
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sec-data-pipeline/extractor/external"
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+type VerifyOptions struct {
+	// Download every object whose checksum is recorded and compare it,
+	// otherwise only the sizes are compared.
+	Hashes bool
+	// Download missing or damaged objects again from EDGAR. Filings whose
+	// objects cannot be downloaded again are marked as failed.
+	Repair bool
+	// Record orphans named like a document of a committed filing as
+	// documents of that filing.
+	AdoptOrphans bool
+	// Delete orphans which were not adopted. Objects of filings which are not
+	// committed yet are never deleted, a run may still be writing them.
+	DeleteOrphans bool
+}
+
+// ObjectIssue is an object which is missing, damaged or not recorded. CIK and
+// SecID name the filing the object belongs to, if any.
+type ObjectIssue struct {
+	Key    string
+	CIK    string
+	SecID  string
+	Reason string
+}
+
+type VerifyReport struct {
+	Filings    int
+	Objects    int
+	Missing    []*ObjectIssue
+	Mismatched []*ObjectIssue
+	Orphans    []*ObjectIssue
+	Pending    []*ObjectIssue
+	Repaired   []string
+	Adopted    []string
+	Deleted    []string
+}
+
+// expectedObject is an object the archive has to hold for the committed
+// filings sharing it, co-registrants file under the same accession number.
+// name is the file of the filing on EDGAR the object was downloaded from.
+type expectedObject struct {
+	key     string
+	name    string
+	main    bool
+	size    int64
+	sha256  string
+	filings []*storage.StoredFiling
+}
+
+// Verify reconciles the archive with the filings in the database. Every
+// object recorded for a committed filing has to exist with the recorded size
+// and checksum, every object in the archive has to be recorded. Depending on
+// opts the differences are repaired.
+func (s *Extractor) Verify(ctx context.Context, opts *VerifyOptions) (*VerifyReport, error) {
+	filings, err := s.db.GetStoredFilings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{Filings: len(filings)}
+	expected, bySecID := expectObjects(filings)
+	listed := make(map[string]bool)
+	err = s.archive.List(ctx, func(obj *storage.ObjectInfo) error {
+		report.Objects++
+		listed[obj.Key] = true
+		exp, ok := expected[obj.Key]
+		if !ok {
+			report.addOrphan(obj.Key, bySecID[secIDOfKey(obj.Key)])
+			return nil
+		}
+		reason, err := s.checkObject(ctx, exp, obj, opts.Hashes)
+		if err != nil {
+			return err
+		}
+		if len(reason) > 0 {
+			report.Mismatched = append(report.Mismatched, exp.issue(reason))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(expected) {
+		if !listed[key] {
+			report.Missing = append(report.Missing, expected[key].issue("missing"))
+		}
+	}
+	if opts.Repair {
+		if err := s.repair(ctx, report, expected); err != nil {
+			return nil, err
+		}
+	}
+	if opts.AdoptOrphans || opts.DeleteOrphans {
+		if err := s.resolveOrphans(ctx, report, bySecID, opts); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// expectObjects returns the objects of the committed filings by key and all
+// filings by accession number. Filings stored before documents were recorded
+// only know their primary document.
+func expectObjects(filings []*storage.StoredFiling) (map[string]*expectedObject, map[string][]*storage.StoredFiling) {
+	expected := make(map[string]*expectedObject)
+	bySecID := make(map[string][]*storage.StoredFiling)
+	expect := func(fil *storage.StoredFiling, key string, name string, size int64, hash string) {
+		if exp, ok := expected[key]; ok {
+			exp.filings = append(exp.filings, fil)
+			return
+		}
+		expected[key] = &expectedObject{
+			key:     key,
+			name:    name,
+			main:    name == fil.OriginalFile,
+			size:    size,
+			sha256:  hash,
+			filings: []*storage.StoredFiling{fil},
+		}
+	}
+	for _, fil := range filings {
+		bySecID[fil.SecID] = append(bySecID[fil.SecID], fil)
+		if fil.State != storage.FilingCommitted {
+			continue
+		}
+		mainKey := ""
+		if ex := path.Ext(fil.OriginalFile); len(ex) > 0 {
+			mainKey = fil.SecID + ex
+		}
+		recorded := false
+		for _, doc := range fil.Documents {
+			expect(fil, doc.StorageKey, doc.Name, doc.Size, doc.SHA256)
+			recorded = recorded || doc.StorageKey == mainKey
+		}
+		if !recorded && len(mainKey) > 0 {
+			expect(fil, mainKey, fil.OriginalFile, fil.Size, fil.SHA256)
+		}
+	}
+	return expected, bySecID
+}
+
+// checkObject returns why an object differs from what was recorded, or an
+// empty string if it matches.
+func (s *Extractor) checkObject(
+	ctx context.Context,
+	exp *expectedObject,
+	obj *storage.ObjectInfo,
+	hashes bool,
+) (string, error) {
+	if exp.size > 0 && obj.Size != exp.size {
+		return fmt.Sprintf("size %d instead of %d", obj.Size, exp.size), nil
+	}
+	if !hashes || len(exp.sha256) < 1 {
+		return "", nil
+	}
+	_, hash, err := s.hashObject(ctx, obj.Key)
+	if err != nil {
+		return "", err
+	}
+	if hash != exp.sha256 {
+		return fmt.Sprintf("SHA-256 %s instead of %s", hash, exp.sha256), nil
+	}
+	return "", nil
+}
+
+func (s *Extractor) hashObject(ctx context.Context, key string) (int64, string, error) {
+	body, err := s.archive.Open(ctx, key)
+	if err != nil {
+		return 0, "", err
+	}
+	defer body.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return 0, "", fmt.Errorf("Could not read object '%s', %w", key, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// repair downloads missing and damaged objects again from the index of their
+// filing and records what was stored. Only the filings of objects which
+// could not be downloaded again are marked as failed, so a run extracts them
+// again if their company is still tracked. Objects which still do not match
+// afterwards stay in the report.
+func (s *Extractor) repair(ctx context.Context, report *VerifyReport, expected map[string]*expectedObject) error {
+	indexes := make(map[string][]*storage.DocumentRecord)
+	for _, issue := range append(report.Missing, report.Mismatched...) {
+		exp := expected[issue.Key]
+		err := s.refetch(ctx, exp, indexes)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if abortErr := s.handleAPIError(issue.CIK, err); abortErr != nil {
+			return abortErr
+		}
+		reason := fmt.Sprintf("Object '%s' %s and could not be downloaded again, %s", issue.Key, issue.Reason, err.Error())
+		for _, fil := range exp.filings {
+			if err := s.db.SetFilingState(ctx, fil.ID, storage.FilingFailed, reason); err != nil {
+				return err
+			}
+		}
+	}
+	filings, err := s.db.GetStoredFilings(ctx)
+	if err != nil {
+		return err
+	}
+	repaired, _ := expectObjects(filings)
+	check := func(issues []*ObjectIssue) ([]*ObjectIssue, error) {
+		var left []*ObjectIssue
+		for _, issue := range issues {
+			exp, ok := repaired[issue.Key]
+			if !ok {
+				left = append(left, issue)
+				continue
+			}
+			obj, err := s.archive.Head(ctx, issue.Key)
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				left = append(left, issue)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			reason, err := s.checkObject(ctx, exp, obj, len(exp.sha256) > 0)
+			if err != nil {
+				return nil, err
+			}
+			if len(reason) > 0 {
+				left = append(left, issue)
+				continue
+			}
+			report.Repaired = append(report.Repaired, issue.Key)
+		}
+		return left, nil
+	}
+	if report.Missing, err = check(report.Missing); err != nil {
+		return err
+	}
+	if report.Mismatched, err = check(report.Mismatched); err != nil {
+		return err
+	}
+	return nil
+}
+
+// refetch downloads the file of an object again into the archive and records
+// its size and checksum for every filing sharing it. indexes keeps the files
+// of each filing's index, so a filing with several broken objects is only
+// listed once.
+func (s *Extractor) refetch(ctx context.Context, exp *expectedObject, indexes map[string][]*storage.DocumentRecord) error {
+	owner := exp.filings[0]
+	fil := external.NewFiling(owner.SecID, owner.OriginalFile)
+	files, ok := indexes[owner.SecID]
+	if !ok {
+		listed, err := s.api.GetFiles(ctx, owner.CIK, fil)
+		if err != nil {
+			return err
+		}
+		for _, f := range listed {
+			files = append(files, &storage.DocumentRecord{Name: f.Name, Size: f.Size, LastModified: f.LastModified})
+		}
+		indexes[owner.SecID] = files
+	}
+	var found *storage.DocumentRecord
+	for _, f := range files {
+		if f.Name == exp.name {
+			found = f
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("%w, file '%s' not in index of filing '%s'", external.ErrNotFound, exp.name, owner.SecID)
+	}
+	size, hash, err := s.archiveFile(ctx, owner.CIK, fil, exp.name, found.Size, exp.key)
+	if err != nil {
+		return err
+	}
+	for _, stored := range exp.filings {
+		doc := &storage.DocumentRecord{
+			FilingID:     stored.ID,
+			Name:         exp.name,
+			StorageKey:   exp.key,
+			Size:         size,
+			SHA256:       hash,
+			LastModified: found.LastModified,
+		}
+		if err := s.db.InsertDocument(ctx, doc); err != nil {
+			return err
+		}
+		if exp.main {
+			if err := s.db.CommitFiling(ctx, stored.ID, doc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveOrphans adopts orphans stored under the key prefix of a committed
+// filing as its documents and deletes the remaining orphans.
+func (s *Extractor) resolveOrphans(
+	ctx context.Context,
+	report *VerifyReport,
+	bySecID map[string][]*storage.StoredFiling,
+	opts *VerifyOptions,
+) error {
+	var left []*ObjectIssue
+	for _, issue := range report.Orphans {
+		name, isDocument := strings.CutPrefix(issue.Key, issue.SecID+"/")
+		if opts.AdoptOrphans && len(issue.SecID) > 0 && isDocument && len(name) > 0 {
+			size, hash, err := s.hashObject(ctx, issue.Key)
+			if err != nil {
+				return err
+			}
+			for _, fil := range bySecID[issue.SecID] {
+				err := s.db.InsertDocument(ctx, &storage.DocumentRecord{
+					FilingID:   fil.ID,
+					Name:       name,
+					StorageKey: issue.Key,
+					Size:       size,
+					SHA256:     hash,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			report.Adopted = append(report.Adopted, issue.Key)
+			continue
+		}
+		if opts.DeleteOrphans {
+			if err := s.archive.Delete(ctx, issue.Key); err != nil {
+				return err
+			}
+			report.Deleted = append(report.Deleted, issue.Key)
+			continue
+		}
+		left = append(left, issue)
+	}
+	report.Orphans = left
+	return nil
+}
+
+// addOrphan sorts an object which is not recorded by the filings sharing its
+// accession number. Objects of filings which are not committed yet are
+// pending, they are recorded once the filing is committed.
+func (r *VerifyReport) addOrphan(key string, filings []*storage.StoredFiling) {
+	issue := &ObjectIssue{Key: key, Reason: "not recorded"}
+	for _, fil := range filings {
+		if fil.State != storage.FilingCommitted {
+			issue.CIK, issue.SecID, issue.Reason = fil.CIK, fil.SecID, "filing "+fil.State
+			r.Pending = append(r.Pending, issue)
+			return
+		}
+	}
+	if len(filings) > 0 {
+		issue.CIK, issue.SecID = filings[0].CIK, filings[0].SecID
+	}
+	r.Orphans = append(r.Orphans, issue)
+}
+
+func (exp *expectedObject) issue(reason string) *ObjectIssue {
+	return &ObjectIssue{Key: exp.key, CIK: exp.filings[0].CIK, SecID: exp.filings[0].SecID, Reason: reason}
+}
+
+// secIDOfKey returns the accession number of a key, primary documents are
+// stored as "<accession>.<ext>" and further documents as "<accession>/<name>".
+func secIDOfKey(key string) string {
+	if i := strings.IndexAny(key, "/."); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+func sortedKeys(expected map[string]*expectedObject) []string {
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
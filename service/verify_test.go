@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sec-data-pipeline/extractor/external/edgartest"
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+func TestVerify(t *testing.T) {
+	const mainKey = "000032019323000106.htm"
+	damaged := bytes.Replace(testDocument, []byte("10-K"), []byte("10-Q"), 1)
+	var tests = []struct {
+		name  string
+		files map[string][]byte
+		opts  *VerifyOptions
+		want  []string
+		clean bool
+	}{
+		{"Accepts consistent archive", nil, &VerifyOptions{Hashes: true}, nil, true},
+		{"Reports missing objects", map[string][]byte{mainKey: nil}, &VerifyOptions{}, []string{"missing " + mainKey}, false},
+		{
+			"Reports objects of wrong size",
+			map[string][]byte{mainKey: []byte("x")},
+			&VerifyOptions{},
+			[]string{"mismatched " + mainKey},
+			false,
+		},
+		{"Skips checksums by default", map[string][]byte{mainKey: damaged}, &VerifyOptions{}, nil, false},
+		{
+			"Reports objects with wrong checksum",
+			map[string][]byte{mainKey: damaged},
+			&VerifyOptions{Hashes: true},
+			[]string{"mismatched " + mainKey},
+			false,
+		},
+		{
+			"Reports orphans",
+			map[string][]byte{"000032019323000106/extra.htm": testDocument, "unknown.htm": testDocument},
+			&VerifyOptions{},
+			[]string{"orphan 000032019323000106/extra.htm", "orphan unknown.htm"},
+			false,
+		},
+		{"Repairs missing objects", map[string][]byte{mainKey: nil}, &VerifyOptions{Repair: true}, []string{"repaired " + mainKey}, true},
+		{
+			"Repairs damaged objects",
+			map[string][]byte{mainKey: damaged},
+			&VerifyOptions{Hashes: true, Repair: true},
+			[]string{"repaired " + mainKey},
+			true,
+		},
+		{
+			"Adopts and deletes orphans",
+			map[string][]byte{"000032019323000106/extra.htm": testDocument, "unknown.htm": testDocument},
+			&VerifyOptions{AdoptOrphans: true, DeleteOrphans: true},
+			[]string{"adopted 000032019323000106/extra.htm", "deleted unknown.htm"},
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			run := newTestRun(t, server, &Options{})
+			if err := run.s.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for key, data := range test.files {
+				path := filepath.Join(run.dest, filepath.FromSlash(key))
+				if data == nil {
+					if err := os.Remove(path); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			report, err := run.s.Verify(context.Background(), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := summarizeReport(report)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			again, err := run.s.Verify(context.Background(), &VerifyOptions{Hashes: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clean := len(again.Missing)+len(again.Mismatched)+len(again.Orphans) == 0
+			if clean != test.clean {
+				t.Errorf("got %v after verifying again", summarizeReport(again))
+			}
+		})
+	}
+}
+
+func summarizeReport(report *VerifyReport) []string {
+	var lines []string
+	for _, issue := range report.Missing {
+		lines = append(lines, "missing "+issue.Key)
+	}
+	for _, issue := range report.Mismatched {
+		lines = append(lines, "mismatched "+issue.Key)
+	}
+	for _, issue := range report.Orphans {
+		lines = append(lines, "orphan "+issue.Key)
+	}
+	for _, key := range report.Repaired {
+		lines = append(lines, "repaired "+key)
+	}
+	for _, key := range report.Adopted {
+		lines = append(lines, "adopted "+key)
+	}
+	for _, key := range report.Deleted {
+		lines = append(lines, "deleted "+key)
+	}
+	return lines
+}
+
+func TestVerifyRepair(t *testing.T) {
+	const mainKey = "000032019323000106.htm"
+	var tests = []struct {
+		name   string
+		fault  *edgartest.Fault
+		state  string
+		missed int
+	}{
+		{"Downloads objects of untracked companies again", nil, storage.FilingCommitted, 0},
+		{"Fails filings it cannot download again", &edgartest.Fault{Status: 404}, storage.FilingFailed, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestEDGAR()
+			defer server.Close()
+			run := newTestRun(t, server, &Options{})
+			if err := run.s.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := run.db.RemoveCompany(context.Background(), "0000320193"); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(run.dest, mainKey)); err != nil {
+				t.Fatal(err)
+			}
+			if test.fault != nil {
+				server.AddFault("/Archives/edgar/data/0000320193/000032019323000106/", *test.fault)
+			}
+			submissions := server.Requests("/submissions/")
+			report, err := run.s.Verify(context.Background(), &VerifyOptions{Repair: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Missing) != test.missed {
+				t.Errorf("got %v, want %d objects left missing", summarizeReport(report), test.missed)
+			}
+			if n := server.Requests("/submissions/"); n != submissions {
+				t.Errorf("got %d submissions requests while repairing, want none", n-submissions)
+			}
+			for _, fil := range run.db.Filings() {
+				if fil.SecID == "000032019323000106" && fil.State != test.state {
+					t.Errorf("got filing %s %s '%s', want %s", fil.SecID, fil.State, fil.StateError, test.state)
+				}
+			}
+		})
+	}
+}
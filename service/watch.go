@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/external"
+)
+
+const (
+	feedPageSize = 100
+	// filings not stored after this many polls are given up on, e.g. when
+	// the company is only a subject and its submissions do not list them
+	watchMaxAttempts = 10
+)
+
+type watcher struct {
+	seen     map[string]bool
+	attempts map[string]int
+}
+
+// Watch polls the latest filings feed every WatchInterval and extracts new
+// filings of tracked companies through the same pipeline as Run as soon as
+// they are accepted. It only returns errors which abort a run, or
+// ErrInterrupted once ctx is done, stopping like Run does.
+func (s *Extractor) Watch(ctx context.Context) error {
+	ctx, cancel, s := s.stoppable(ctx)
+	defer cancel()
+	w := &watcher{seen: make(map[string]bool), attempts: make(map[string]int)}
+	for !s.shutdown.stopping() {
+		if err := s.poll(ctx, w); err != nil {
+			return errors.Join(err, s.shutdown.report(s.logger))
+		}
+		select {
+		case <-time.After(s.opts.WatchInterval):
+		case <-s.shutdown.stop.Done():
+		}
+	}
+	return s.shutdown.report(s.logger)
+}
+
+func (s *Extractor) poll(ctx context.Context, w *watcher) error {
+	entries, err := s.readFeed(ctx, w)
+	if err != nil {
+		if errors.Is(err, external.ErrRateLimited) || errors.Is(err, external.ErrBlockPage) {
+			return fmt.Errorf("Aborting watch, %w", err)
+		}
+		s.logger.Log("Could not read latest filings feed, " + err.Error())
+		return nil
+	}
+	defer w.prune(entries)
+	pending := make(map[string][]*external.FeedEntry)
+	names := make(map[string]string)
+	for _, e := range entries {
+		if w.seen[e.GetID()] {
+			continue
+		}
+		if !s.opts.Policies.For(e.CIK).AllowsForm(e.Form) {
+			w.seen[e.GetID()] = true
+			continue
+		}
+		pending[e.CIK] = append(pending[e.CIK], e)
+		names[e.CIK] = e.CompanyName
+	}
+	if len(pending) < 1 {
+		return nil
+	}
+	if s.opts.Universe {
+		for cik, name := range names {
+			if _, err := s.db.DiscoverCompany(ctx, cik, name); err != nil {
+				return err
+			}
+		}
+	}
+	companies, err := s.db.GetCompanies(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cmp := range companies {
+		cik, err := external.NormalizeCIK(cmp.CIK)
+		if err != nil || len(pending[cik]) < 1 {
+			continue
+		}
+		got, err := s.db.GetFilingIDs(ctx, cmp.ID)
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for _, e := range pending[cik] {
+			if slices.Contains(got, e.GetID()) {
+				w.seen[e.GetID()] = true
+				continue
+			}
+			missing = append(missing, e.AccessionNumber)
+		}
+		delete(pending, cik)
+		if len(missing) < 1 {
+			continue
+		}
+		s.logger.Log(fmt.Sprintf(
+			"Found filings %s of company '%s' in latest filings feed",
+			strings.Join(missing, ", "),
+			cmp.CIK,
+		))
+		sub, err := s.processCompany(ctx, cmp.ID, cmp.CIK, got)
+		if err != nil {
+			return err
+		}
+		// only committed filings are done, failed ones are tried again by
+		// the next poll
+		got, err = s.db.GetFilingIDs(ctx, cmp.ID)
+		if err != nil {
+			return err
+		}
+		for _, accession := range missing {
+			id := strings.Replace(accession, "-", "", -1)
+			w.attempts[id]++
+			switch {
+			case slices.Contains(got, id), sub != nil && excluded(sub, id):
+				w.seen[id] = true
+			case w.attempts[id] >= watchMaxAttempts:
+				s.logger.Log(fmt.Sprintf(
+					"Giving up on filing '%s' of company '%s', not stored after %d attempts",
+					accession,
+					cmp.CIK,
+					w.attempts[id],
+				))
+				w.seen[id] = true
+			}
+		}
+	}
+	// the rest belongs to companies which are not tracked
+	for _, rest := range pending {
+		for _, e := range rest {
+			w.seen[e.GetID()] = true
+		}
+	}
+	return nil
+}
+
+// prune forgets the filings which left the feed window of the last poll, so
+// the watcher does not grow for the life of the process.
+func (w *watcher) prune(entries []*external.FeedEntry) {
+	window := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		window[e.GetID()] = true
+	}
+	for id := range w.seen {
+		if !window[id] {
+			delete(w.seen, id)
+		}
+	}
+	for id := range w.attempts {
+		if !window[id] {
+			delete(w.attempts, id)
+		}
+	}
+}
+
+// readFeed pages through the feed until it reaches entries seen in an earlier
+// poll, so bursts of filings between two polls are not missed.
+func (s *Extractor) readFeed(ctx context.Context, w *watcher) ([]*external.FeedEntry, error) {
+	var entries []*external.FeedEntry
+	for page := 0; page < s.opts.WatchPages; page++ {
+		found, err := s.api.GetCurrentFilings(ctx, page*feedPageSize, feedPageSize)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+		if len(found) < feedPageSize {
+			break
+		}
+		reached := false
+		for _, e := range found {
+			reached = reached || w.seen[e.GetID()]
+		}
+		if reached {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// excluded reports whether the submissions of a company list a filing which
+// is never extracted, as it is skipped by policy or quarantined.
+func excluded(sub *external.Submissions, id string) bool {
+	for _, sk := range sub.Skipped {
+		if strings.Replace(sk.SecID, "-", "", -1) == id {
+			return true
+		}
+	}
+	for _, rj := range sub.Rejected {
+		if strings.Replace(rj.SecID, "-", "", -1) == id {
+			return true
+		}
+	}
+	return false
+}
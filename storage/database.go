@@ -1,30 +1,168 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-type company struct {
-	ID  int
-	CIK string
+// Company is a company tracked or discovered by the extractor.
+type Company struct {
+	ID   int
+	CIK  string
+	Name string
+}
+
+// States of the lifecycle of a filing. Only committed filings count as
+// extracted, every other state is resumed by the next run.
+const (
+	FilingDiscovered  = "discovered"
+	FilingDownloading = "downloading"
+	FilingArchived    = "archived"
+	FilingCommitted   = "committed"
+	FilingFailed      = "failed"
+)
+
+type FilingRecord struct {
+	CompanyID    int
+	SecID        string
+	Form         string
+	OriginalFile string
+	FilingDate   sql.NullTime
+	ReportDate   sql.NullTime
+	AcceptDate   sql.NullTime
+	LastModified sql.NullTime
+	Size         int64
+	SHA256       string
+
+	PrimaryDocDescription string
+	Act                   string
+	FileNumber            string
+	FilmNumber            string
+	Items                 string
+	SubmissionSize        int64
+	IsXBRL                bool
+	IsInlineXBRL          bool
+
+	// set by the database
+	ID            int
+	State         string
+	StateError    string
+	Attempts      int
+	DisappearedAt sql.NullTime
+}
+
+type DocumentRecord struct {
+	FilingID     int
+	Name         string
+	StorageKey   string
+	Size         int64
+	SHA256       string
+	LastModified sql.NullTime
+}
+
+type AddressRecord struct {
+	Street1 string
+	Street2 string
+	City    string
+	State   string
+	Zip     string
+	Phone   string
+}
+
+type PartyRecord struct {
+	Role                 string
+	CIK                  string
+	Name                 string
+	SIC                  string
+	IRSNumber            string
+	StateOfIncorporation string
+	FiscalYearEnd        string
+	FilerStatus          string
+	FormType             string
+	Act                  string
+	FileNumber           string
+	FilmNumber           string
+	BusinessAddress      AddressRecord
+	MailAddress          AddressRecord
+}
+
+type HeaderRecord struct {
+	PeriodOfReport sql.NullTime
+	Items          []string
+	Parties        []*PartyRecord
+}
+
+type CompanyRecord struct {
+	Name                 string
+	EntityType           string
+	SIC                  string
+	SICDescription       string
+	Tickers              []string
+	Exchanges            []string
+	EIN                  string
+	Category             string
+	FiscalYearEnd        string
+	StateOfIncorporation string
+	Phone                string
+	BusinessAddress      AddressRecord
+	MailAddress          AddressRecord
+	FormerNames          []*FormerNameRecord
+}
+
+type FormerNameRecord struct {
+	Name string
+	From sql.NullTime
+	To   sql.NullTime
+}
+
+type QuarantineRecord struct {
+	CompanyID int
+	SecID     string
+	Form      string
+	Reason    string
+	Row       string
+}
+
+// CoverageRecord compares the filings EDGAR lists for a company with the
+// filings stored. Expected are the listed filings allowed by the form policy.
+type CoverageRecord struct {
+	CompanyID   int
+	Listed      int
+	Expected    int
+	Stored      int
+	Missing     int
+	Disappeared int
+	Coverage    float64
+}
+
+// StoredFiling is a filing in any state of its lifecycle together with the
+// CIK of its company and the documents recorded for it.
+type StoredFiling struct {
+	FilingRecord
+	CIK       string
+	Documents []*DocumentRecord
 }
 
 type Database interface {
-	GetCompanies() ([]*company, error)
-	GetFilingIDs(cmpID int) ([]string, error)
-	InsertFiling(
-		cmpID int,
-		secID string,
-		form string,
-		ogFile string,
-		filDate sql.NullTime,
-		repDate sql.NullTime,
-		acptDate sql.NullTime,
-		lMDate sql.NullTime,
-	) error
+	GetCompanies(ctx context.Context) ([]*Company, error)
+	AddCompany(ctx context.Context, cik string, name string) (bool, error)
+	RemoveCompany(ctx context.Context, cik string) (bool, error)
+	DiscoverCompany(ctx context.Context, cik string, name string) (bool, error)
+	GetFilingIDs(ctx context.Context, cmpID int) ([]string, error)
+	GetStoredFilings(ctx context.Context) ([]*StoredFiling, error)
+	BeginFiling(ctx context.Context, fil *FilingRecord) (int, error)
+	SetFilingState(ctx context.Context, filingID int, state string, reason string) error
+	CommitFiling(ctx context.Context, filingID int, mainDoc *DocumentRecord) error
+	InsertDocument(ctx context.Context, doc *DocumentRecord) error
+	InsertHeader(ctx context.Context, filingID int, hdr *HeaderRecord) error
+	UpdateCompany(ctx context.Context, cmpID int, cmp *CompanyRecord) (bool, error)
+	QuarantineFiling(ctx context.Context, rec *QuarantineRecord) (bool, error)
+	MarkDisappeared(ctx context.Context, cmpID int, secIDs []string) error
+	InsertCoverage(ctx context.Context, rec *CoverageRecord) error
 }
 
 type postgresDB struct {
@@ -57,20 +195,23 @@ func NewPostgres(params *postgresParams) (*postgresDB, error) {
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
 	return &postgresDB{db}, nil
 }
 
-func (db *postgresDB) GetCompanies() ([]*company, error) {
-	stmt := `SELECT id, cik FROM company;`
-	rows, err := db.Query(stmt)
+func (db *postgresDB) GetCompanies(ctx context.Context) ([]*Company, error) {
+	stmt := `SELECT id, cik, COALESCE(name, '') FROM company WHERE tracked ORDER BY cik;`
+	rows, err := db.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var companies []*company
+	var companies []*Company
 	for rows.Next() {
-		var tmp company
-		if err := rows.Scan(&tmp.ID, &tmp.CIK); err != nil {
+		var tmp Company
+		if err := rows.Scan(&tmp.ID, &tmp.CIK, &tmp.Name); err != nil {
 			return nil, err
 		}
 		companies = append(companies, &tmp)
@@ -78,10 +219,55 @@ func (db *postgresDB) GetCompanies() ([]*company, error) {
 	return companies, nil
 }
 
-func (db *postgresDB) GetFilingIDs(cmpID int) ([]string, error) {
+// AddCompany starts tracking a company, reporting false if it was already
+// tracked. Companies removed before are tracked again with their filings.
+func (db *postgresDB) AddCompany(ctx context.Context, cik string, name string) (bool, error) {
+	stmt := `UPDATE company SET tracked = true WHERE cik = $1 AND NOT tracked;`
+	res, err := db.ExecContext(ctx, stmt, cik)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+		return affected > 0, err
+	}
+	stmt = `INSERT INTO company (cik, name)
+	SELECT $1, NULLIF($2, '') WHERE NOT EXISTS (SELECT 1 FROM company WHERE cik = $1);`
+	res, err = db.ExecContext(ctx, stmt, cik, name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveCompany stops tracking a company but keeps its filings, reporting
+// false if it was not tracked.
+func (db *postgresDB) RemoveCompany(ctx context.Context, cik string) (bool, error) {
+	res, err := db.ExecContext(ctx, `UPDATE company SET tracked = false WHERE cik = $1 AND tracked;`, cik)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// DiscoverCompany tracks a company which is not known yet, reporting false
+// if it was known. Unlike AddCompany it never tracks removed companies again.
+func (db *postgresDB) DiscoverCompany(ctx context.Context, cik string, name string) (bool, error) {
+	stmt := `INSERT INTO company (cik, name)
+	SELECT $1, NULLIF($2, '') WHERE NOT EXISTS (SELECT 1 FROM company WHERE cik = $1);`
+	res, err := db.ExecContext(ctx, stmt, cik, name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *postgresDB) GetFilingIDs(ctx context.Context, cmpID int) ([]string, error) {
 	stmt := `SELECT sec_id FROM filing, company 
-	WHERE filing.company_id = company.id AND company.id = $1;`
-	rows, err := db.Query(stmt, cmpID)
+	WHERE filing.company_id = company.id AND company.id = $1 AND filing.state = $2;`
+	rows, err := db.QueryContext(ctx, stmt, cmpID, FilingCommitted)
 	if err != nil {
 		return nil, err
 	}
@@ -97,16 +283,84 @@ func (db *postgresDB) GetFilingIDs(cmpID int) ([]string, error) {
 	return ids, nil
 }
 
-func (db *postgresDB) InsertFiling(
-	cmpID int,
-	secID string,
-	form string,
-	ogFile string,
-	filDate sql.NullTime,
-	repDate sql.NullTime,
-	acptDate sql.NullTime,
-	lMDate sql.NullTime,
-) error {
+// GetStoredFilings returns every filing of every company, tracked or not,
+// with its documents, in the order of the CIKs and accession numbers.
+func (db *postgresDB) GetStoredFilings(ctx context.Context) ([]*StoredFiling, error) {
+	stmt := `SELECT
+		filing.id,
+		filing.company_id,
+		company.cik,
+		filing.sec_id,
+		COALESCE(filing.form, ''),
+		COALESCE(filing.original_file, ''),
+		COALESCE(filing.size, 0),
+		COALESCE(filing.sha256, ''),
+		filing.state
+	FROM filing, company
+	WHERE filing.company_id = company.id
+	ORDER BY company.cik, filing.sec_id;`
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var filings []*StoredFiling
+	byID := make(map[int]*StoredFiling)
+	for rows.Next() {
+		var tmp StoredFiling
+		err := rows.Scan(
+			&tmp.ID,
+			&tmp.CompanyID,
+			&tmp.CIK,
+			&tmp.SecID,
+			&tmp.Form,
+			&tmp.OriginalFile,
+			&tmp.Size,
+			&tmp.SHA256,
+			&tmp.State,
+		)
+		if err != nil {
+			return nil, err
+		}
+		filings = append(filings, &tmp)
+		byID[tmp.ID] = &tmp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stmt = `SELECT
+		filing_id,
+		name,
+		storage_key,
+		COALESCE(size, 0),
+		COALESCE(sha256, ''),
+		last_modified_date
+	FROM document ORDER BY id;`
+	docRows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer docRows.Close()
+	for docRows.Next() {
+		var tmp DocumentRecord
+		err := docRows.Scan(&tmp.FilingID, &tmp.Name, &tmp.StorageKey, &tmp.Size, &tmp.SHA256, &tmp.LastModified)
+		if err != nil {
+			return nil, err
+		}
+		if fil, ok := byID[tmp.FilingID]; ok {
+			fil.Documents = append(fil.Documents, &tmp)
+		}
+	}
+	if err := docRows.Err(); err != nil {
+		return nil, err
+	}
+	return filings, nil
+}
+
+// BeginFiling records a filing as discovered before any of its documents are
+// archived. A filing left over from an earlier attempt is reset and its
+// attempts are counted, committed filings are never touched.
+func (db *postgresDB) BeginFiling(ctx context.Context, fil *FilingRecord) (int, error) {
 	stmt := `INSERT INTO filing (
 		company_id,
 		sec_id,
@@ -115,11 +369,392 @@ func (db *postgresDB) InsertFiling(
 		filing_date,
 		report_date,
 		acceptance_date,
+		primary_doc_description,
+		act,
+		file_number,
+		film_number,
+		items,
+		submission_size,
+		is_xbrl,
+		is_inline_xbrl,
+		state
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8,
+		$9, $10, $11, $12, $13, $14, $15, $16
+	)
+	ON CONFLICT (company_id, sec_id) DO UPDATE SET
+		form = EXCLUDED.form,
+		original_file = EXCLUDED.original_file,
+		filing_date = EXCLUDED.filing_date,
+		report_date = EXCLUDED.report_date,
+		acceptance_date = EXCLUDED.acceptance_date,
+		primary_doc_description = EXCLUDED.primary_doc_description,
+		act = EXCLUDED.act,
+		file_number = EXCLUDED.file_number,
+		film_number = EXCLUDED.film_number,
+		items = EXCLUDED.items,
+		submission_size = EXCLUDED.submission_size,
+		is_xbrl = EXCLUDED.is_xbrl,
+		is_inline_xbrl = EXCLUDED.is_inline_xbrl,
+		state = EXCLUDED.state,
+		state_error = NULL,
+		state_updated_at = now(),
+		attempts = filing.attempts + 1
+	WHERE filing.state <> $17
+	RETURNING id;`
+	var id int
+	err := db.QueryRowContext(
+		ctx,
+		stmt,
+		fil.CompanyID,
+		fil.SecID,
+		fil.Form,
+		fil.OriginalFile,
+		fil.FilingDate,
+		fil.ReportDate,
+		fil.AcceptDate,
+		fil.PrimaryDocDescription,
+		fil.Act,
+		fil.FileNumber,
+		fil.FilmNumber,
+		sql.NullString{String: fil.Items, Valid: len(fil.Items) > 0},
+		fil.SubmissionSize,
+		fil.IsXBRL,
+		fil.IsInlineXBRL,
+		FilingDiscovered,
+		FilingCommitted,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("Filing '%s' is already committed", fil.SecID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// SetFilingState moves a filing to the next state of its lifecycle, reason
+// explains why a filing failed.
+func (db *postgresDB) SetFilingState(ctx context.Context, filingID int, state string, reason string) error {
+	stmt := `UPDATE filing SET
+		state = $2,
+		state_error = $3,
+		state_updated_at = now()
+	WHERE id = $1;`
+	_, err := db.ExecContext(ctx, stmt, filingID, state, sql.NullString{String: reason, Valid: len(reason) > 0})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CommitFiling stores the primary document of an archived filing and marks
+// the filing as committed, which makes it count as extracted.
+func (db *postgresDB) CommitFiling(ctx context.Context, filingID int, mainDoc *DocumentRecord) error {
+	stmt := `UPDATE filing SET
+		original_file = $2,
+		last_modified_date = $3,
+		size = $4,
+		sha256 = $5,
+		state = $6,
+		state_error = NULL,
+		state_updated_at = now()
+	WHERE id = $1;`
+	_, err := db.ExecContext(
+		ctx,
+		stmt,
+		filingID,
+		mainDoc.Name,
+		mainDoc.LastModified,
+		mainDoc.Size,
+		mainDoc.SHA256,
+		FilingCommitted,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *postgresDB) InsertDocument(ctx context.Context, doc *DocumentRecord) error {
+	stmt := `INSERT INTO document (
+		filing_id,
+		name,
+		storage_key,
+		size,
+		sha256,
 		last_modified_date
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
-	_, err := db.Exec(stmt, cmpID, secID, form, ogFile, filDate, repDate, acptDate, lMDate)
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (filing_id, name) DO UPDATE SET
+		storage_key = EXCLUDED.storage_key,
+		size = EXCLUDED.size,
+		sha256 = EXCLUDED.sha256,
+		last_modified_date = EXCLUDED.last_modified_date;`
+	_, err := db.ExecContext(
+		ctx,
+		stmt,
+		doc.FilingID,
+		doc.Name,
+		doc.StorageKey,
+		doc.Size,
+		doc.SHA256,
+		doc.LastModified,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// QuarantineFiling records an invalid row of the submissions JSON, a row
+// rejected again only refreshes when it was last seen. It reports whether the
+// row was quarantined for the first time.
+func (db *postgresDB) QuarantineFiling(ctx context.Context, rec *QuarantineRecord) (bool, error) {
+	stmt := `INSERT INTO filing_quarantine (
+		company_id,
+		sec_id,
+		form,
+		reason,
+		row_data
+	) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (company_id, sec_id, reason) DO UPDATE SET
+		form = EXCLUDED.form,
+		row_data = EXCLUDED.row_data,
+		last_seen_at = now()
+	RETURNING xmax = 0;`
+	var inserted bool
+	err := db.QueryRowContext(ctx, stmt, rec.CompanyID, rec.SecID, rec.Form, rec.Reason, rec.Row).Scan(&inserted)
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+// MarkDisappeared flags the filings of a company which EDGAR no longer
+// lists, keeping when they were first missed, and clears the flag of every
+// other filing of the company.
+func (db *postgresDB) MarkDisappeared(ctx context.Context, cmpID int, secIDs []string) error {
+	stmt := `UPDATE filing SET disappeared_at = CASE
+		WHEN sec_id = ANY($2) THEN COALESCE(disappeared_at, now())
+		ELSE NULL
+	END
+	WHERE company_id = $1 AND (disappeared_at IS NOT NULL OR sec_id = ANY($2));`
+	_, err := db.ExecContext(ctx, stmt, cmpID, pq.Array(secIDs))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *postgresDB) InsertCoverage(ctx context.Context, rec *CoverageRecord) error {
+	stmt := `INSERT INTO company_coverage (
+		company_id,
+		listed,
+		expected,
+		stored,
+		missing,
+		disappeared,
+		coverage
+	) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	_, err := db.ExecContext(
+		ctx,
+		stmt,
+		rec.CompanyID,
+		rec.Listed,
+		rec.Expected,
+		rec.Stored,
+		rec.Missing,
+		rec.Disappeared,
+		rec.Coverage,
+	)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// InsertHeader stores the header metadata of a filing, replacing the parties
+// of an earlier attempt.
+func (db *postgresDB) InsertHeader(ctx context.Context, filingID int, hdr *HeaderRecord) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := `UPDATE filing SET period_of_report = $2, items = COALESCE($3, items) WHERE id = $1;`
+	items := sql.NullString{String: strings.Join(hdr.Items, ","), Valid: len(hdr.Items) > 0}
+	if _, err := tx.ExecContext(ctx, stmt, filingID, hdr.PeriodOfReport, items); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM filing_party WHERE filing_id = $1;`, filingID); err != nil {
+		return err
+	}
+	stmt = `INSERT INTO filing_party (
+		filing_id,
+		role,
+		cik,
+		name,
+		sic,
+		irs_number,
+		state_of_incorporation,
+		fiscal_year_end,
+		filer_status,
+		form_type,
+		act,
+		file_number,
+		film_number,
+		business_street1,
+		business_street2,
+		business_city,
+		business_state,
+		business_zip,
+		business_phone,
+		mail_street1,
+		mail_street2,
+		mail_city,
+		mail_state,
+		mail_zip
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+		$13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24
+	);`
+	for _, p := range hdr.Parties {
+		_, err := tx.ExecContext(
+			ctx,
+			stmt,
+			filingID,
+			p.Role,
+			p.CIK,
+			p.Name,
+			p.SIC,
+			p.IRSNumber,
+			p.StateOfIncorporation,
+			p.FiscalYearEnd,
+			p.FilerStatus,
+			p.FormType,
+			p.Act,
+			p.FileNumber,
+			p.FilmNumber,
+			p.BusinessAddress.Street1,
+			p.BusinessAddress.Street2,
+			p.BusinessAddress.City,
+			p.BusinessAddress.State,
+			p.BusinessAddress.Zip,
+			p.BusinessAddress.Phone,
+			p.MailAddress.Street1,
+			p.MailAddress.Street2,
+			p.MailAddress.City,
+			p.MailAddress.State,
+			p.MailAddress.Zip,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateCompany syncs the profile of a company and reports whether it changed.
+// Every changed profile is also added to company_history, so renames and
+// relocations can be traced.
+func (db *postgresDB) UpdateCompany(ctx context.Context, cmpID int, cmp *CompanyRecord) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	stmt := `UPDATE company SET
+		name = $2,
+		entity_type = $3,
+		sic = $4,
+		sic_description = $5,
+		tickers = $6,
+		exchanges = $7,
+		ein = $8,
+		category = $9,
+		fiscal_year_end = $10,
+		state_of_incorporation = $11,
+		phone = $12,
+		business_street1 = $13,
+		business_street2 = $14,
+		business_city = $15,
+		business_state = $16,
+		business_zip = $17,
+		mail_street1 = $18,
+		mail_street2 = $19,
+		mail_city = $20,
+		mail_state = $21,
+		mail_zip = $22,
+		updated_at = now()
+	WHERE id = $1 AND (
+		name, entity_type, sic, sic_description, tickers, exchanges, ein,
+		category, fiscal_year_end, state_of_incorporation, phone,
+		business_street1, business_street2, business_city, business_state, business_zip,
+		mail_street1, mail_street2, mail_city, mail_state, mail_zip
+	) IS DISTINCT FROM (
+		$2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+		$13, $14, $15, $16, $17, $18, $19, $20, $21, $22
+	);`
+	res, err := tx.ExecContext(
+		ctx,
+		stmt,
+		cmpID,
+		cmp.Name,
+		cmp.EntityType,
+		cmp.SIC,
+		cmp.SICDescription,
+		strings.Join(cmp.Tickers, ","),
+		strings.Join(cmp.Exchanges, ","),
+		cmp.EIN,
+		cmp.Category,
+		cmp.FiscalYearEnd,
+		cmp.StateOfIncorporation,
+		cmp.Phone,
+		cmp.BusinessAddress.Street1,
+		cmp.BusinessAddress.Street2,
+		cmp.BusinessAddress.City,
+		cmp.BusinessAddress.State,
+		cmp.BusinessAddress.Zip,
+		cmp.MailAddress.Street1,
+		cmp.MailAddress.Street2,
+		cmp.MailAddress.City,
+		cmp.MailAddress.State,
+		cmp.MailAddress.Zip,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		stmt = `INSERT INTO company_history (
+			company_id, name, entity_type, sic, sic_description, tickers, exchanges, ein,
+			category, fiscal_year_end, state_of_incorporation, phone,
+			business_street1, business_street2, business_city, business_state, business_zip,
+			mail_street1, mail_street2, mail_city, mail_state, mail_zip
+		) SELECT
+			id, name, entity_type, sic, sic_description, tickers, exchanges, ein,
+			category, fiscal_year_end, state_of_incorporation, phone,
+			business_street1, business_street2, business_city, business_state, business_zip,
+			mail_street1, mail_street2, mail_city, mail_state, mail_zip
+		FROM company WHERE id = $1;`
+		if _, err := tx.ExecContext(ctx, stmt, cmpID); err != nil {
+			return false, err
+		}
+	}
+	stmt = `INSERT INTO company_former_name (company_id, name, from_date, to_date)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (company_id, name, from_date) DO UPDATE SET to_date = EXCLUDED.to_date;`
+	for _, v := range cmp.FormerNames {
+		// rows without a start date would never conflict and pile up
+		if !v.From.Valid {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt, cmpID, v.Name, v.From, v.To); err != nil {
+			return false, err
+		}
+	}
+	return affected > 0, tx.Commit()
+}
@@ -2,39 +2,152 @@ package storage
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
+var ErrObjectNotFound = errors.New("Object not found")
+
 type FileStorage interface {
-	PutObject(key string, data []byte) error
+	PutObject(ctx context.Context, key string, data []byte) error
+	PutStream(ctx context.Context, key string, r io.Reader, size int64) error
+	List(ctx context.Context, fn func(obj *ObjectInfo) error) error
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
 }
 
 type s3Bucket struct {
-	name   string
-	client *s3.S3
+	name     string
+	client   *s3.S3
+	uploader *s3manager.Uploader
 }
 
 func NewS3Bucket(awsSession *session.Session, name string) *s3Bucket {
-	return &s3Bucket{name: name, client: s3.New(awsSession)}
+	client := s3.New(awsSession)
+	return &s3Bucket{name: name, client: client, uploader: s3manager.NewUploaderWithClient(client)}
 }
 
-func (b *s3Bucket) PutObject(key string, data []byte) error {
+func (b *s3Bucket) PutObject(ctx context.Context, key string, data []byte) error {
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(b.name),
 		Key:    aws.String(key),
 		Body:   bytes.NewReader(data),
 	}
-	_, err := b.client.PutObject(input)
+	_, err := b.client.PutObjectWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// PutStream uploads in parts, so only a few parts are held in memory at once.
+// A size of -1 means the length is unknown. The uploader aborts the multipart
+// upload if reading from r fails.
+func (b *s3Bucket) PutStream(ctx context.Context, key string, r io.Reader, size int64) error {
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(key),
+		Body:   r,
+	}
+	_, err := b.uploader.UploadWithContext(ctx, input, func(u *s3manager.Uploader) {
+		if size > u.PartSize*int64(u.MaxUploadParts) {
+			u.PartSize = size/int64(u.MaxUploadParts) + 1
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// List calls fn for every object of the bucket, page by page.
+func (b *s3Bucket) List(ctx context.Context, fn func(obj *ObjectInfo) error) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(b.name)}
+	var fnErr error
+	err := b.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			fnErr = fn(&ObjectInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+			if fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+func (b *s3Bucket) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{Bucket: aws.String(b.name), Key: aws.String(key)}
+	out, err := b.client.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, fmt.Errorf("%w, %s", ErrObjectNotFound, key)
+		}
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		LastModified: aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+func (b *s3Bucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(b.name), Key: aws.String(key)}
+	out, err := b.client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, fmt.Errorf("%w, %s", ErrObjectNotFound, key)
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (b *s3Bucket) Delete(ctx context.Context, key string) error {
+	input := &s3.DeleteObjectInput{Bucket: aws.String(b.name), Key: aws.String(key)}
+	_, err := b.client.DeleteObjectWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func isS3NotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	return aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound"
+}
+
 type folder struct {
 	path string
 }
@@ -43,10 +156,113 @@ func NewFolder(path string) *folder {
 	return &folder{path: path}
 }
 
-func (f *folder) PutObject(key string, data []byte) error {
+func (f *folder) PutObject(ctx context.Context, key string, data []byte) error {
 	err := os.WriteFile(f.path+"/"+key, data, 0666)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// PutStream writes into a temporary file next to the target and renames it
+// once complete, so a failed or cancelled download never leaves a partial
+// object behind.
+func (f *folder) PutStream(ctx context.Context, key string, r io.Reader, size int64) error {
+	target := filepath.Join(f.path, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// List walks the folder and calls fn for every object in it, leaving out the
+// temporary files of uploads in progress.
+func (f *folder) List(ctx context.Context, fn func(obj *ObjectInfo) error) error {
+	return filepath.WalkDir(f.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || isTempFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		key, err := filepath.Rel(f.path, path)
+		if err != nil {
+			return err
+		}
+		return fn(&ObjectInfo{Key: filepath.ToSlash(key), Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+func (f *folder) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := os.Stat(filepath.Join(f.path, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w, %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+func (f *folder) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(f.path, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w, %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *folder) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(f.path, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isTempFile matches the names PutStream gives its temporary files.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp")
+}
+
+// contextReader stops a copy once ctx is done, even if r keeps delivering.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
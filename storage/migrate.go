@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// The company and filing tables are created outside of this repository,
+// migrations only contain the changes the extractor depends on.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+func migrate(db *sql.DB) error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migration (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+	if _, err := db.Exec(stmt); err != nil {
+		return err
+	}
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := applyMigration(db, name); err != nil {
+			return errors.New("Could not apply migration " + name + ", " + err.Error())
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Serializes migrations of extractors starting at the same time.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(4224);`); err != nil {
+		return err
+	}
+	var applied bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM schema_migration WHERE version = $1);`
+	if err := tx.QueryRow(stmt, name).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+	content, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(string(content)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migration (version) VALUES ($1);`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,2 @@
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS size BIGINT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS sha256 TEXT;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS document (
+	id SERIAL PRIMARY KEY,
+	filing_id INTEGER NOT NULL REFERENCES filing (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	storage_key TEXT NOT NULL,
+	size BIGINT,
+	sha256 TEXT,
+	last_modified_date TIMESTAMP,
+	UNIQUE (filing_id, name)
+);
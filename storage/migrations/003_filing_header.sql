@@ -0,0 +1,33 @@
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS period_of_report DATE;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS items TEXT;
+
+CREATE TABLE IF NOT EXISTS filing_party (
+	id SERIAL PRIMARY KEY,
+	filing_id INTEGER NOT NULL REFERENCES filing (id) ON DELETE CASCADE,
+	role TEXT NOT NULL,
+	cik TEXT,
+	name TEXT,
+	sic TEXT,
+	irs_number TEXT,
+	state_of_incorporation TEXT,
+	fiscal_year_end TEXT,
+	filer_status TEXT,
+	form_type TEXT,
+	act TEXT,
+	file_number TEXT,
+	film_number TEXT,
+	business_street1 TEXT,
+	business_street2 TEXT,
+	business_city TEXT,
+	business_state TEXT,
+	business_zip TEXT,
+	business_phone TEXT,
+	mail_street1 TEXT,
+	mail_street2 TEXT,
+	mail_city TEXT,
+	mail_state TEXT,
+	mail_zip TEXT
+);
+
+CREATE INDEX IF NOT EXISTS filing_party_filing_id_idx ON filing_party (filing_id);
+CREATE INDEX IF NOT EXISTS filing_party_cik_idx ON filing_party (cik);
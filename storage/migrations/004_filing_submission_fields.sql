@@ -0,0 +1,7 @@
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS primary_doc_description TEXT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS act TEXT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS file_number TEXT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS film_number TEXT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS submission_size BIGINT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS is_xbrl BOOLEAN;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS is_inline_xbrl BOOLEAN;
@@ -0,0 +1,62 @@
+ALTER TABLE company ADD COLUMN IF NOT EXISTS name TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS entity_type TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS sic TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS sic_description TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS tickers TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS exchanges TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS ein TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS category TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS fiscal_year_end TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS state_of_incorporation TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS phone TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS business_street1 TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS business_street2 TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS business_city TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS business_state TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS business_zip TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS mail_street1 TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS mail_street2 TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS mail_city TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS mail_state TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS mail_zip TEXT;
+ALTER TABLE company ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+-- Every version of a company profile, a new row is added whenever a sync
+-- changes any of the profile columns.
+CREATE TABLE IF NOT EXISTS company_history (
+	id SERIAL PRIMARY KEY,
+	company_id INTEGER NOT NULL REFERENCES company (id) ON DELETE CASCADE,
+	recorded_at TIMESTAMP NOT NULL DEFAULT now(),
+	name TEXT,
+	entity_type TEXT,
+	sic TEXT,
+	sic_description TEXT,
+	tickers TEXT,
+	exchanges TEXT,
+	ein TEXT,
+	category TEXT,
+	fiscal_year_end TEXT,
+	state_of_incorporation TEXT,
+	phone TEXT,
+	business_street1 TEXT,
+	business_street2 TEXT,
+	business_city TEXT,
+	business_state TEXT,
+	business_zip TEXT,
+	mail_street1 TEXT,
+	mail_street2 TEXT,
+	mail_city TEXT,
+	mail_state TEXT,
+	mail_zip TEXT
+);
+
+CREATE INDEX IF NOT EXISTS company_history_company_id_idx ON company_history (company_id);
+
+CREATE TABLE IF NOT EXISTS company_former_name (
+	id SERIAL PRIMARY KEY,
+	company_id INTEGER NOT NULL REFERENCES company (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	from_date TIMESTAMP,
+	to_date TIMESTAMP,
+	UNIQUE (company_id, name, from_date)
+);
@@ -0,0 +1 @@
+ALTER TABLE company ADD COLUMN IF NOT EXISTS tracked BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,13 @@
+-- Rows of the submissions JSON which failed validation, kept with the reason
+-- and their raw values instead of being stored as filings.
+CREATE TABLE IF NOT EXISTS filing_quarantine (
+	id SERIAL PRIMARY KEY,
+	company_id INTEGER NOT NULL REFERENCES company (id) ON DELETE CASCADE,
+	sec_id TEXT NOT NULL,
+	form TEXT,
+	reason TEXT NOT NULL,
+	row_data TEXT,
+	first_seen_at TIMESTAMP NOT NULL DEFAULT now(),
+	last_seen_at TIMESTAMP NOT NULL DEFAULT now(),
+	UNIQUE (company_id, sec_id, reason)
+);
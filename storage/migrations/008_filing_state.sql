@@ -0,0 +1,11 @@
+-- Lifecycle of a filing: discovered, downloading, archived, committed or
+-- failed. Rows stored before the lifecycle existed are assumed committed
+-- without any check, run `extractor verify -repair` after upgrading so the
+-- filings whose objects are missing or damaged are downloaded again.
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'committed';
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS state_error TEXT;
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS state_updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS filing_company_id_sec_id_idx ON filing (company_id, sec_id);
+CREATE INDEX IF NOT EXISTS filing_state_idx ON filing (state) WHERE state <> 'committed';
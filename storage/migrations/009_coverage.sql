@@ -0,0 +1,17 @@
+-- Coverage of the filings EDGAR lists for a company, one row per audit.
+CREATE TABLE IF NOT EXISTS company_coverage (
+	id SERIAL PRIMARY KEY,
+	company_id INTEGER NOT NULL REFERENCES company (id) ON DELETE CASCADE,
+	listed INTEGER NOT NULL,
+	expected INTEGER NOT NULL,
+	stored INTEGER NOT NULL,
+	missing INTEGER NOT NULL,
+	disappeared INTEGER NOT NULL,
+	coverage NUMERIC(5, 2) NOT NULL,
+	audited_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS company_coverage_company_id_idx ON company_coverage (company_id, audited_at);
+
+-- Set while a stored filing is no longer listed by EDGAR, e.g. after it was
+-- withdrawn or deleted.
+ALTER TABLE filing ADD COLUMN IF NOT EXISTS disappeared_at TIMESTAMP;
@@ -0,0 +1,295 @@
+// Package storagetest provides an in-memory database for integration tests
+// which run the extractor against a fake EDGAR without a Postgres server.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sec-data-pipeline/extractor/storage"
+)
+
+// MemoryDB implements storage.Database by keeping everything in memory.
+type MemoryDB struct {
+	mu        sync.Mutex
+	companies []*memoryCompany
+	filings   []*storage.FilingRecord
+	documents []*storage.DocumentRecord
+	headers   map[int]*storage.HeaderRecord
+	rejected  []*storage.QuarantineRecord
+	coverage  []*storage.CoverageRecord
+}
+
+type memoryCompany struct {
+	storage.Company
+	tracked bool
+	profile *storage.CompanyRecord
+}
+
+// NewMemoryDB returns an empty database.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{headers: make(map[int]*storage.HeaderRecord)}
+}
+
+func (db *MemoryDB) GetCompanies(ctx context.Context) ([]*storage.Company, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var companies []*storage.Company
+	for _, cmp := range db.companies {
+		if cmp.tracked {
+			tmp := cmp.Company
+			companies = append(companies, &tmp)
+		}
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].CIK < companies[j].CIK })
+	return companies, nil
+}
+
+func (db *MemoryDB) AddCompany(ctx context.Context, cik string, name string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, cmp := range db.companies {
+		if cmp.CIK == cik {
+			changed := !cmp.tracked
+			cmp.tracked = true
+			return changed, nil
+		}
+	}
+	db.companies = append(db.companies, &memoryCompany{
+		Company: storage.Company{ID: len(db.companies) + 1, CIK: cik, Name: name},
+		tracked: true,
+	})
+	return true, nil
+}
+
+func (db *MemoryDB) RemoveCompany(ctx context.Context, cik string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, cmp := range db.companies {
+		if cmp.CIK == cik && cmp.tracked {
+			cmp.tracked = false
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (db *MemoryDB) DiscoverCompany(ctx context.Context, cik string, name string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, cmp := range db.companies {
+		if cmp.CIK == cik {
+			return false, nil
+		}
+	}
+	db.companies = append(db.companies, &memoryCompany{
+		Company: storage.Company{ID: len(db.companies) + 1, CIK: cik, Name: name},
+		tracked: true,
+	})
+	return true, nil
+}
+
+func (db *MemoryDB) GetFilingIDs(ctx context.Context, cmpID int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var ids []string
+	for _, fil := range db.filings {
+		if fil.CompanyID == cmpID && fil.State == storage.FilingCommitted {
+			ids = append(ids, fil.SecID)
+		}
+	}
+	return ids, nil
+}
+
+func (db *MemoryDB) GetStoredFilings(ctx context.Context) ([]*storage.StoredFiling, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var filings []*storage.StoredFiling
+	for _, fil := range db.filings {
+		tmp := &storage.StoredFiling{FilingRecord: *fil, CIK: db.companies[fil.CompanyID-1].CIK}
+		for _, doc := range db.documents {
+			if doc.FilingID == fil.ID {
+				docTmp := *doc
+				tmp.Documents = append(tmp.Documents, &docTmp)
+			}
+		}
+		filings = append(filings, tmp)
+	}
+	sort.SliceStable(filings, func(i, j int) bool {
+		if filings[i].CIK != filings[j].CIK {
+			return filings[i].CIK < filings[j].CIK
+		}
+		return filings[i].SecID < filings[j].SecID
+	})
+	return filings, nil
+}
+
+func (db *MemoryDB) BeginFiling(ctx context.Context, fil *storage.FilingRecord) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tmp := *fil
+	tmp.State = storage.FilingDiscovered
+	tmp.Attempts = 1
+	for i, v := range db.filings {
+		if v == nil || v.CompanyID != fil.CompanyID || v.SecID != fil.SecID {
+			continue
+		}
+		if v.State == storage.FilingCommitted {
+			return 0, fmt.Errorf("Filing '%s' is already committed", fil.SecID)
+		}
+		tmp.ID = v.ID
+		tmp.Attempts = v.Attempts + 1
+		db.filings[i] = &tmp
+		return tmp.ID, nil
+	}
+	tmp.ID = len(db.filings) + 1
+	db.filings = append(db.filings, &tmp)
+	return tmp.ID, nil
+}
+
+func (db *MemoryDB) SetFilingState(ctx context.Context, filingID int, state string, reason string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	fil := db.filings[filingID-1]
+	fil.State = state
+	fil.StateError = reason
+	return nil
+}
+
+func (db *MemoryDB) CommitFiling(ctx context.Context, filingID int, mainDoc *storage.DocumentRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	fil := db.filings[filingID-1]
+	fil.OriginalFile = mainDoc.Name
+	fil.LastModified = mainDoc.LastModified
+	fil.Size = mainDoc.Size
+	fil.SHA256 = mainDoc.SHA256
+	fil.State = storage.FilingCommitted
+	fil.StateError = ""
+	return nil
+}
+
+func (db *MemoryDB) InsertDocument(ctx context.Context, doc *storage.DocumentRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tmp := *doc
+	for i, v := range db.documents {
+		if v.FilingID == doc.FilingID && v.Name == doc.Name {
+			db.documents[i] = &tmp
+			return nil
+		}
+	}
+	db.documents = append(db.documents, &tmp)
+	return nil
+}
+
+func (db *MemoryDB) QuarantineFiling(ctx context.Context, rec *storage.QuarantineRecord) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tmp := *rec
+	for i, v := range db.rejected {
+		if v.CompanyID == rec.CompanyID && v.SecID == rec.SecID && v.Reason == rec.Reason {
+			db.rejected[i] = &tmp
+			return false, nil
+		}
+	}
+	db.rejected = append(db.rejected, &tmp)
+	return true, nil
+}
+
+func (db *MemoryDB) MarkDisappeared(ctx context.Context, cmpID int, secIDs []string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, fil := range db.filings {
+		if fil.CompanyID != cmpID {
+			continue
+		}
+		switch {
+		case !slices.Contains(secIDs, fil.SecID):
+			fil.DisappearedAt = sql.NullTime{}
+		case !fil.DisappearedAt.Valid:
+			fil.DisappearedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDB) InsertCoverage(ctx context.Context, rec *storage.CoverageRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tmp := *rec
+	db.coverage = append(db.coverage, &tmp)
+	return nil
+}
+
+func (db *MemoryDB) InsertHeader(ctx context.Context, filingID int, hdr *storage.HeaderRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.headers[filingID] = hdr
+	return nil
+}
+
+func (db *MemoryDB) UpdateCompany(ctx context.Context, cmpID int, cmp *storage.CompanyRecord) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, v := range db.companies {
+		if v.ID == cmpID {
+			changed := !reflect.DeepEqual(v.profile, cmp)
+			v.profile = cmp
+			v.Name = cmp.Name
+			return changed, nil
+		}
+	}
+	return false, nil
+}
+
+// Filings returns copies of all filings in the order they were inserted.
+func (db *MemoryDB) Filings() []storage.FilingRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	filings := make([]storage.FilingRecord, 0, len(db.filings))
+	for _, fil := range db.filings {
+		filings = append(filings, *fil)
+	}
+	return filings
+}
+
+// Documents returns copies of all documents in the order they were inserted.
+func (db *MemoryDB) Documents() []storage.DocumentRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	documents := make([]storage.DocumentRecord, 0, len(db.documents))
+	for _, doc := range db.documents {
+		documents = append(documents, *doc)
+	}
+	return documents
+}
+
+// Quarantined returns copies of all quarantined rows.
+func (db *MemoryDB) Quarantined() []storage.QuarantineRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	rejected := make([]storage.QuarantineRecord, 0, len(db.rejected))
+	for _, rec := range db.rejected {
+		rejected = append(rejected, *rec)
+	}
+	return rejected
+}
+
+// Coverage returns copies of all coverage records in the order they were
+// inserted.
+func (db *MemoryDB) Coverage() []storage.CoverageRecord {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	coverage := make([]storage.CoverageRecord, 0, len(db.coverage))
+	for _, rec := range db.coverage {
+		coverage = append(coverage, *rec)
+	}
+	return coverage
+}
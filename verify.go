@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sec-data-pipeline/extractor/service"
+)
+
+const verifyUsage = "usage: extractor verify [-hashes] [-repair] [-adopt-orphans] [-delete-orphans]"
+
+// runVerify compares the archive with the database and prints every object
+// which is missing, damaged or not recorded, e.g. "extractor verify -repair".
+// It fails when differences are left after the requested repairs.
+func runVerify(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	opts := &service.VerifyOptions{}
+	flags.BoolVar(&opts.Hashes, "hashes", false, "")
+	flags.BoolVar(&opts.Repair, "repair", false, "")
+	flags.BoolVar(&opts.AdoptOrphans, "adopt-orphans", false, "")
+	flags.BoolVar(&opts.DeleteOrphans, "delete-orphans", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errors.New(verifyUsage)
+	}
+	extractor, err := newExtractor()
+	if err != nil {
+		return err
+	}
+	report, err := extractor.Verify(ctx, opts)
+	if err != nil {
+		return err
+	}
+	printIssues("missing", report.Missing)
+	printIssues("mismatched", report.Mismatched)
+	printIssues("orphan", report.Orphans)
+	printIssues("pending", report.Pending)
+	for _, key := range report.Repaired {
+		fmt.Printf("repaired\t%s\n", key)
+	}
+	for _, key := range report.Adopted {
+		fmt.Printf("adopted\t%s\n", key)
+	}
+	for _, key := range report.Deleted {
+		fmt.Printf("deleted\t%s\n", key)
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"Checked %d objects of %d filings: %d missing, %d mismatched, %d orphans, %d pending, %d repaired, %d adopted, %d deleted\n",
+		report.Objects,
+		report.Filings,
+		len(report.Missing),
+		len(report.Mismatched),
+		len(report.Orphans),
+		len(report.Pending),
+		len(report.Repaired),
+		len(report.Adopted),
+		len(report.Deleted),
+	)
+	if len(report.Missing)+len(report.Mismatched)+len(report.Orphans) > 0 {
+		return errors.New("Archive and database differ")
+	}
+	return nil
+}
+
+func printIssues(kind string, issues []*service.ObjectIssue) {
+	for _, issue := range issues {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", kind, issue.Key, issue.CIK, issue.SecID, issue.Reason)
+	}
+}